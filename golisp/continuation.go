@@ -0,0 +1,65 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// continuation is the rest of the computation from a call/cc. Evaluation uses
+// the Go stack, so continuations are escaping: calling one unwinds to its
+// call/cc and returns from it, which is only possible while that call/cc is
+// still running. Calling a continuation after its call/cc has returned, to
+// re-enter it, is an error.
+type continuation struct {
+	active bool
+}
+
+// unwind is the error by which a continuation unwinds to its call/cc.
+type unwind struct {
+	k *continuation
+	v *object
+}
+
+func (e *unwind) Error() string {
+	return "continuation called outside its call/cc"
+}
+
+var errReentry = errors.New("continuation called after its call/cc returned: re-entry is not supported")
+
+// callCC calls proc with the current continuation.
+func callCC(proc *object) (*object, error) {
+	k := &continuation{active: true}
+	res, err := apply(proc, newObject(func(o ...*object) (*object, error) {
+		if !k.active {
+			return nil, errReentry
+		}
+		switch len(o) {
+		case 0:
+			return nil, &unwind{k, nil}
+		case 1:
+			return nil, &unwind{k, o[0]}
+		}
+		return nil, fmt.Errorf("expected at most one argument to continuation, got %d", len(o))
+	}))
+	k.active = false
+
+	var e *unwind
+	if errors.As(err, &e) && e.k == k {
+		return e.v, nil
+	}
+	return res, err
+}
+
+// dynamicWind calls before, thunk and after in order and returns the value of
+// thunk. after is called however thunk is left, whether by returning,
+// calling a continuation or raising an error.
+func dynamicWind(before, thunk, after *object) (*object, error) {
+	if _, err := apply(before); err != nil {
+		return nil, err
+	}
+	res, err := apply(thunk)
+	if _, aerr := apply(after); aerr != nil {
+		return nil, aerr
+	}
+	return res, err
+}
@@ -0,0 +1,92 @@
+package golisp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCallCC(t *testing.T) {
+	cases := []evalTest{
+		{
+			name:    "return",
+			program: "(call/cc (lambda (k) 42))",
+			want:    "42",
+		},
+		{
+			name:    "escape",
+			program: "(+ 1 (call/cc (lambda (k) (+ 10 (k 2)))))",
+			want:    "3",
+		},
+		{
+			name: "early exit from map",
+			program: `(define (first-negative l)
+					(call-with-current-continuation
+						(lambda (return)
+							(map (lambda (x) (if (< x 0) (return x) x)) l)
+							0)))
+				(list (first-negative '(1 -2 3 -4)) (first-negative '(1 2)))`,
+			want: "(-2 0)",
+		},
+		{
+			name: "nested",
+			program: `(call/cc (lambda (outer)
+					(+ 1 (call/cc (lambda (inner) (outer 5))))))`,
+			want: "5",
+		},
+		{
+			name: "inner",
+			program: `(call/cc (lambda (outer)
+					(+ 1 (call/cc (lambda (inner) (inner 5))))))`,
+			want: "6",
+		},
+		{
+			name: "re-entry",
+			program: `(define saved 0)
+				(define n (call/cc (lambda (k) (set! saved k) 1)))
+				(saved 2)`,
+			wantErr: "continuation called after its call/cc returned: re-entry is not supported",
+		},
+		{
+			name:    "arity",
+			program: "(call/cc (lambda (k) (k 1 2)))",
+			wantErr: "expected at most one argument to continuation, got 2",
+		},
+		{
+			name: "dynamic-wind",
+			program: `(define trace '())
+				(define (note x) (set! trace (cons x trace)))
+				(dynamic-wind (lambda () (note 'before)) (lambda () (note 'during) 1) (lambda () (note 'after)))
+				trace`,
+			want: "(after during before)",
+		},
+		{
+			name: "dynamic-wind escape",
+			program: `(define trace '())
+				(define (note x) (set! trace (cons x trace)))
+				(define res (call/cc (lambda (k)
+					(dynamic-wind
+						(lambda () (note 'before))
+						(lambda () (k 'escaped) (note 'unreached))
+						(lambda () (note 'after))))))
+				(list res trace)`,
+			want: "(escaped (after before))",
+		},
+	}
+
+	runEvalTests(t, cases)
+
+	// The after thunk runs when the body fails.
+	i := New()
+	_, err := i.Eval(`(define trace '())
+		(dynamic-wind (lambda () 0) (lambda () (car 1)) (lambda () (set! trace 'after)))`)
+	if err == nil || !strings.Contains(err.Error(), "expected pair as argument to car") {
+		t.Errorf("dynamic-wind error: got error %v, want %q", err, "expected pair as argument to car")
+	}
+	got, err := i.Eval("trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "after" {
+		t.Errorf("dynamic-wind error: got trace %s, want after", got)
+	}
+}
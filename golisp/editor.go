@@ -0,0 +1,569 @@
+package golisp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by a lineReader when the user interrupts input.
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads lines of input for the REPL.
+type lineReader interface {
+	// readLine shows prompt and returns the next line without its newline.
+	// It returns io.EOF at the end of the input and errInterrupted if the
+	// user interrupts.
+	readLine(prompt string) (string, error)
+}
+
+// scanReader is a lineReader for input that is not a terminal.
+type scanReader struct {
+	out       io.Writer
+	lines     <-chan string
+	errc      <-chan error
+	interrupt <-chan os.Signal
+}
+
+func newScanReader(in io.Reader, out io.Writer, interrupt <-chan os.Signal) *scanReader {
+	lines := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+		errc <- scanner.Err()
+	}()
+	return &scanReader{out: out, lines: lines, errc: errc, interrupt: interrupt}
+}
+
+func (r *scanReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	select {
+	case <-r.interrupt:
+		fmt.Fprintln(r.out)
+		return "", errInterrupted
+	case in, ok := <-r.lines:
+		if !ok {
+			if err := <-r.errc; err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return in, nil
+	}
+}
+
+// Keys that are not runes.
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+// Control keys.
+const (
+	ctrlA     = 'a' - 'a' + 1
+	ctrlB     = 'b' - 'a' + 1
+	ctrlC     = 'c' - 'a' + 1
+	ctrlD     = 'd' - 'a' + 1
+	ctrlE     = 'e' - 'a' + 1
+	ctrlF     = 'f' - 'a' + 1
+	ctrlG     = 'g' - 'a' + 1
+	ctrlH     = 'h' - 'a' + 1
+	tab       = 'i' - 'a' + 1
+	ctrlK     = 'k' - 'a' + 1
+	ctrlN     = 'n' - 'a' + 1
+	ctrlP     = 'p' - 'a' + 1
+	ctrlR     = 'r' - 'a' + 1
+	ctrlU     = 'u' - 'a' + 1
+	escape    = 27
+	backspace = 127
+)
+
+const (
+	highlightOn  = "\x1b[7m"
+	highlightOff = "\x1b[0m"
+)
+
+// maxHistory is the number of lines of history that are kept.
+const maxHistory = 1000
+
+// editor is a lineReader for terminals in raw mode. It supports cursor
+// movement, history with reverse search, completion and highlights the paren
+// matching the one at the cursor.
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// width is the number of columns of the terminal.
+	width int
+	// complete returns the completions of a prefix.
+	complete func(prefix string) []string
+
+	history     []string
+	historyFile string
+	// saved is the number of lines in the history file.
+	saved int
+
+	// The line being edited.
+	prompt string
+	buf    []rune
+	pos    int
+	// off is the index of the first rune of buf shown, for lines that are
+	// too long for the terminal.
+	off int
+}
+
+func newEditor(in io.Reader, out io.Writer, width int, complete func(string) []string) *editor {
+	return &editor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		width:    width,
+		complete: complete,
+	}
+}
+
+// defaultHistoryFile returns the path of the history file in the user's home
+// directory.
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".golisp_history")
+}
+
+// loadHistory reads history from path. A missing file is not an error.
+func (ed *editor) loadHistory(path string) error {
+	ed.historyFile = path
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ed.history = append(ed.history, scanner.Text())
+	}
+	ed.saved = len(ed.history)
+	if len(ed.history) > maxHistory {
+		ed.history = ed.history[len(ed.history)-maxHistory:]
+	}
+	return scanner.Err()
+}
+
+// addHistory appends line to the history and the history file. Once the file
+// holds maxHistory lines it is rewritten with only the lines that are kept.
+func (ed *editor) addHistory(line string) error {
+	if strings.TrimSpace(line) == "" || (len(ed.history) != 0 && ed.history[len(ed.history)-1] == line) {
+		return nil
+	}
+	ed.history = append(ed.history, line)
+	if len(ed.history) > maxHistory {
+		ed.history = ed.history[1:]
+	}
+	if ed.historyFile == "" {
+		return nil
+	}
+	flag, lines := os.O_APPEND, []string{line}
+	if ed.saved >= maxHistory {
+		flag, lines = os.O_TRUNC, ed.history
+	}
+	f, err := os.OpenFile(ed.historyFile, os.O_WRONLY|os.O_CREATE|flag, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if flag == os.O_TRUNC {
+		ed.saved = 0
+	}
+	ed.saved += len(lines)
+	return f.Close()
+}
+
+// readKey returns the next key pressed, decoding escape sequences.
+func (ed *editor) readKey() (rune, error) {
+	r, _, err := ed.in.ReadRune()
+	if err != nil || r != escape {
+		return r, err
+	}
+
+	r, _, err = ed.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+	// Read the parameters up to the final byte of the sequence.
+	seq := string(r)
+	for {
+		r, _, err = ed.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		seq += string(r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+	switch seq {
+	case "[A", "OA":
+		return keyUp, nil
+	case "[B", "OB":
+		return keyDown, nil
+	case "[C", "OC":
+		return keyRight, nil
+	case "[D", "OD":
+		return keyLeft, nil
+	case "[H", "OH", "[1~", "[7~":
+		return keyHome, nil
+	case "[F", "OF", "[4~", "[8~":
+		return keyEnd, nil
+	case "[3~":
+		return keyDelete, nil
+	}
+	return keyUnknown, nil
+}
+
+func (ed *editor) readLine(prompt string) (string, error) {
+	ed.prompt = prompt
+	ed.buf = ed.buf[:0]
+	ed.pos = 0
+	ed.off = 0
+	// hist is the index of the history entry being shown, and saved the line
+	// being edited before moving through the history.
+	hist := len(ed.history)
+	var saved []rune
+
+	ed.refresh(true)
+	for {
+		k, err := ed.readKey()
+		if err != nil {
+			return "", err
+		}
+
+	handle:
+		switch k {
+		case '\r', '\n':
+			ed.refresh(false)
+			fmt.Fprint(ed.out, "\r\n")
+			return string(ed.buf), nil
+		case ctrlC:
+			fmt.Fprint(ed.out, "^C\r\n")
+			return "", errInterrupted
+		case ctrlD:
+			if len(ed.buf) == 0 {
+				fmt.Fprint(ed.out, "\r\n")
+				return "", io.EOF
+			}
+			ed.delete()
+		case keyDelete:
+			ed.delete()
+		case backspace, ctrlH:
+			if ed.pos > 0 {
+				ed.pos--
+				ed.delete()
+			}
+		case keyLeft, ctrlB:
+			if ed.pos > 0 {
+				ed.pos--
+			}
+		case keyRight, ctrlF:
+			if ed.pos < len(ed.buf) {
+				ed.pos++
+			}
+		case keyHome, ctrlA:
+			ed.pos = 0
+		case keyEnd, ctrlE:
+			ed.pos = len(ed.buf)
+		case ctrlK:
+			ed.buf = ed.buf[:ed.pos]
+		case ctrlU:
+			ed.buf = append(ed.buf[:0], ed.buf[ed.pos:]...)
+			ed.pos = 0
+		case keyUp, ctrlP:
+			if hist == 0 {
+				break
+			}
+			if hist == len(ed.history) {
+				saved = append(saved[:0], ed.buf...)
+			}
+			hist--
+			ed.set([]rune(ed.history[hist]))
+		case keyDown, ctrlN:
+			if hist == len(ed.history) {
+				break
+			}
+			hist++
+			if hist == len(ed.history) {
+				ed.set(saved)
+			} else {
+				ed.set([]rune(ed.history[hist]))
+			}
+		case tab:
+			ed.completeWord()
+		case ctrlR:
+			// The key that ended the search is handled as if it had been
+			// typed after the line found.
+			k, err = ed.search()
+			if err != nil {
+				return "", err
+			}
+			hist = len(ed.history)
+			if k != 0 {
+				goto handle
+			}
+		default:
+			if unicode.IsPrint(k) {
+				ed.insert(k)
+			}
+		}
+		ed.refresh(true)
+	}
+}
+
+// set replaces the line with l and moves the cursor to the end.
+func (ed *editor) set(l []rune) {
+	ed.buf = append(ed.buf[:0], l...)
+	ed.pos = len(ed.buf)
+}
+
+func (ed *editor) insert(rs ...rune) {
+	ed.buf = append(ed.buf[:ed.pos], append(rs, ed.buf[ed.pos:]...)...)
+	ed.pos += len(rs)
+}
+
+// delete deletes the rune under the cursor.
+func (ed *editor) delete() {
+	if ed.pos < len(ed.buf) {
+		ed.buf = append(ed.buf[:ed.pos], ed.buf[ed.pos+1:]...)
+	}
+}
+
+// refresh redraws the line, highlighting the paren matching the one at the
+// cursor if highlight is set.
+func (ed *editor) refresh(highlight bool) {
+	prompt := []rune(ed.prompt)
+	// Leave a column for the cursor at the end of the line.
+	avail := ed.width - len(prompt) - 1
+	if avail < 1 {
+		avail = 1
+	}
+	if ed.pos < ed.off {
+		ed.off = ed.pos
+	}
+	if ed.pos-ed.off > avail {
+		ed.off = ed.pos - avail
+	}
+	end := ed.off + avail
+	if end > len(ed.buf) {
+		end = len(ed.buf)
+	}
+
+	match := -1
+	if highlight {
+		match = matchParen(ed.buf, ed.pos)
+	}
+
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(ed.prompt)
+	for i := ed.off; i < end; i++ {
+		if i == match {
+			b.WriteString(highlightOn)
+			b.WriteRune(ed.buf[i])
+			b.WriteString(highlightOff)
+		} else {
+			b.WriteRune(ed.buf[i])
+		}
+	}
+	// Clear the rest of the line and move the cursor into place.
+	b.WriteString("\x1b[K\r")
+	if col := len(prompt) + ed.pos - ed.off; col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	fmt.Fprint(ed.out, b.String())
+}
+
+// matchParen returns the index of the paren matching the one before pos if it
+// is a close paren, or the one at pos if it is an open paren, and -1 if there
+// is none. Parens in strings and comments are ignored.
+func matchParen(buf []rune, pos int) int {
+	// Find the runes that are code rather than strings or comments.
+	code := make([]bool, len(buf))
+	inString, inComment := false, false
+	for i := 0; i < len(buf); i++ {
+		switch {
+		case inComment:
+		case inString:
+			if buf[i] == '\\' {
+				i++
+			} else if buf[i] == '"' {
+				inString = false
+			}
+		case buf[i] == '"':
+			inString = true
+		case buf[i] == ';':
+			inComment = true
+		default:
+			code[i] = true
+		}
+	}
+
+	switch {
+	case pos > 0 && buf[pos-1] == ')' && code[pos-1]:
+		depth := 0
+		for i := pos - 1; i >= 0; i-- {
+			if !code[i] {
+				continue
+			}
+			switch buf[i] {
+			case ')':
+				depth++
+			case '(':
+				depth--
+				if depth == 0 {
+					return i
+				}
+			}
+		}
+	case pos < len(buf) && buf[pos] == '(' && code[pos]:
+		depth := 0
+		for i := pos; i < len(buf); i++ {
+			if !code[i] {
+				continue
+			}
+			switch buf[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// completeWord completes the word before the cursor. If it has more than one
+// completion it is extended by their common prefix, and if that does not
+// extend it the completions are listed.
+func (ed *editor) completeWord() {
+	if ed.complete == nil {
+		return
+	}
+	start := ed.pos
+	for start > 0 && !isDelimiter(ed.buf[start-1]) {
+		start--
+	}
+	prefix := ed.buf[start:ed.pos]
+	candidates := ed.complete(string(prefix))
+	if len(candidates) == 0 {
+		return
+	}
+
+	// Compare runes rather than bytes so that the common prefix never ends
+	// part way through a multi-byte rune.
+	common := []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		r := []rune(c)
+		n := 0
+		for n < len(common) && n < len(r) && common[n] == r[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(candidates) == 1 {
+		common = append(common, ' ')
+	}
+	if len(common) > len(prefix) {
+		ed.insert(common[len(prefix):]...)
+		return
+	}
+	fmt.Fprintf(ed.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+}
+
+// search runs an incremental reverse search of the history. It returns the
+// key that ended the search, or 0 if the search was cancelled.
+func (ed *editor) search() (rune, error) {
+	var query []rune
+	orig := append([]rune(nil), ed.buf...)
+	// from is the index of the history entry to search back from.
+	from := len(ed.history) - 1
+	found := true
+
+	find := func() {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(ed.history[i], string(query)) {
+				from = i
+				ed.set([]rune(ed.history[i]))
+				found = true
+				return
+			}
+		}
+		found = false
+	}
+
+	for {
+		status := "reverse-i-search"
+		if !found {
+			status = "failing " + status
+		}
+		fmt.Fprintf(ed.out, "\r(%s)`%s': %s\x1b[K", status, string(query), string(ed.buf))
+
+		k, err := ed.readKey()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case k == ctrlR:
+			if from > 0 {
+				from--
+			}
+			find()
+		case k == backspace || k == ctrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				from = len(ed.history) - 1
+				find()
+			}
+		case k == ctrlG || k == ctrlC:
+			ed.set(orig)
+			return 0, nil
+		case k >= 0 && unicode.IsPrint(k):
+			query = append(query, k)
+			find()
+		default:
+			return k, nil
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package golisp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	up    = "\x1b[A"
+	down  = "\x1b[B"
+	right = "\x1b[C"
+	left  = "\x1b[D"
+	home  = "\x1b[H"
+	end   = "\x1b[F"
+	del   = "\x1b[3~"
+)
+
+func TestEditorReadLine(t *testing.T) {
+	cases := []struct {
+		name    string
+		history []string
+		keys    string
+		want    string
+		wantErr error
+	}{
+		{name: "type", keys: "(+ 1 2)\r", want: "(+ 1 2)"},
+		{name: "movement", keys: "abc" + left + left + "X" + home + "Y" + end + "Z\r", want: "YaXbcZ"},
+		{name: "emacs movement", keys: "abc\x02\x02X\x01Y\x05Z\x06\r", want: "YaXbcZ"},
+		{name: "backspace", keys: "abcd\x7f" + left + "\x7f\r", want: "ac"},
+		{name: "delete", keys: "abcd" + home + del + right + "\x04\r", want: "bd"},
+		{name: "kill", keys: "abcd" + left + left + "\x0b" + left + "\x15\r", want: "b"},
+		{name: "interrupt", keys: "abc\x03", wantErr: errInterrupted},
+		{name: "eof", keys: "\x04", wantErr: io.EOF},
+		{name: "end of input", keys: "abc", wantErr: io.EOF},
+		{name: "unknown keys", keys: "a\x1bb\x1b[5~\x07\r", want: "a"},
+		{name: "history", history: []string{"first", "second"}, keys: up + up + up + down + "!\r", want: "second!"},
+		{name: "history restores line", history: []string{"first"}, keys: "x" + up + down + "y\r", want: "xy"},
+		{name: "emacs history", history: []string{"first", "second"}, keys: "\x10\x10\x0e\r", want: "second"},
+		{
+			name:    "search",
+			history: []string{"(define a 1)", "(car x)", "(define b 2)"},
+			keys:    "\x12def\r",
+			want:    "(define b 2)",
+		},
+		{
+			name:    "search again",
+			history: []string{"(define a 1)", "(car x)", "(define b 2)"},
+			keys:    "\x12def\x12\r",
+			want:    "(define a 1)",
+		},
+		{
+			name:    "search then edit",
+			history: []string{"(define a 1)", "(car x)", "(define b 2)"},
+			keys:    "\x12car" + left + "!\r",
+			want:    "(car x!)",
+		},
+		{
+			name:    "search backspace",
+			history: []string{"(define a 1)", "(car x)"},
+			keys:    "\x12cx\x7f\r",
+			want:    "(car x)",
+		},
+		{
+			name:    "search cancel",
+			history: []string{"(define a 1)"},
+			keys:    "abc\x12def\x07\r",
+			want:    "abc",
+		},
+	}
+
+	for _, tt := range cases {
+		var out bytes.Buffer
+		ed := newEditor(strings.NewReader(tt.keys), &out, 80, nil)
+		ed.history = tt.history
+		got, err := ed.readLine("> ")
+		if err != tt.wantErr {
+			t.Errorf("%s: got err %v, want err %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEditorComplete(t *testing.T) {
+	complete := func(prefix string) []string {
+		var res []string
+		for _, c := range []string{"car", "string-append", "string-length", "naïve", "naíve"} {
+			if strings.HasPrefix(c, prefix) {
+				res = append(res, c)
+			}
+		}
+		return res
+	}
+
+	cases := []struct {
+		keys, want, wantOut string
+	}{
+		{keys: "(ca\t\r", want: "(car "},
+		{keys: "(str\t\r", want: "(string-"},
+		{keys: "(string-\t\r", want: "(string-", wantOut: "\r\nstring-append  string-length\r\n"},
+		{keys: "(x\t\r", want: "(x"},
+		{keys: "(na\t\r", want: "(na", wantOut: "\r\nnaïve  naíve\r\n"},
+	}
+	for _, tt := range cases {
+		var out bytes.Buffer
+		ed := newEditor(strings.NewReader(tt.keys), &out, 80, complete)
+		got, err := ed.readLine("> ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.keys, got, tt.want)
+		}
+		if !strings.Contains(out.String(), tt.wantOut) {
+			t.Errorf("%q: got output %q, want it to contain %q", tt.keys, out.String(), tt.wantOut)
+		}
+	}
+}
+
+func TestEditorHighlight(t *testing.T) {
+	var out bytes.Buffer
+	ed := newEditor(strings.NewReader("(a (b))\r"), &out, 80, nil)
+	if _, err := ed.readLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	// Typing the last paren highlights the first and the line is redrawn
+	// without highlighting when it is entered.
+	want := "\r> " + highlightOn + "(" + highlightOff + "a (b))\x1b[K\r\x1b[9C" +
+		"\r> (a (b))\x1b[K\r\x1b[9C\r\n"
+	if got := out.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, want suffix %q", got, want)
+	}
+}
+
+func TestEditorScroll(t *testing.T) {
+	var out bytes.Buffer
+	ed := newEditor(strings.NewReader("0123456789abcdef"+home), &out, 10, nil)
+	ed.readLine("> ")
+	// The prompt and a column for the cursor leave seven columns.
+	want := "\r> 0123456\x1b[K\r\x1b[2C"
+	if got := out.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, want suffix %q", got, want)
+	}
+	if !strings.Contains(out.String(), "\r> 9abcdef\x1b[K\r\x1b[9C") {
+		t.Errorf("got %q, want the end of the line shown", out.String())
+	}
+}
+
+func TestMatchParen(t *testing.T) {
+	cases := []struct {
+		line string
+		pos  int
+		want int
+	}{
+		{line: "(a (b))", pos: 7, want: 0},
+		{line: "(a (b))", pos: 6, want: 3},
+		{line: "(a (b))", pos: 0, want: 6},
+		{line: "(a (b))", pos: 3, want: 5},
+		{line: "(a (b))", pos: 2, want: -1},
+		{line: "(a \")\" b)", pos: 9, want: 0},
+		{line: "(a \"(\" b)", pos: 0, want: 8},
+		{line: "(a \"\\\"(\" b)", pos: 0, want: 10},
+		{line: "(a ; )", pos: 0, want: -1},
+		{line: "a)", pos: 2, want: -1},
+		{line: "", pos: 0, want: -1},
+	}
+	for _, tt := range cases {
+		if got := matchParen([]rune(tt.line), tt.pos); got != tt.want {
+			t.Errorf("%q at %d: got %d, want %d", tt.line, tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestEditorHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golisp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history")
+
+	ed := newEditor(nil, ioutil.Discard, 80, nil)
+	if err := ed.loadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"(define a 1)", "", "a", "a", "(+ a 1)"} {
+		if err := ed.addHistory(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ed = newEditor(nil, ioutil.Discard, 80, nil)
+	if err := ed.loadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"(define a 1)", "a", "(+ a 1)"}
+	if !reflect.DeepEqual(ed.history, want) {
+		t.Errorf("got %q, want %q", ed.history, want)
+	}
+}
+
+func TestEditorHistoryLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golisp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history")
+
+	ed := newEditor(nil, ioutil.Discard, 80, nil)
+	if err := ed.loadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxHistory+10; i++ {
+		if err := ed.addHistory(fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != maxHistory {
+		t.Errorf("got %d lines in history file, want %d", len(lines), maxHistory)
+	}
+	if got, want := lines[len(lines)-1], fmt.Sprint(maxHistory+9); got != want {
+		t.Errorf("got last line %q, want %q", got, want)
+	}
+}
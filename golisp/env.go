@@ -5,12 +5,32 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"reflect"
+	"strings"
+	"unicode/utf8"
 )
 
 type env struct {
 	outer *env
 	m     map[string]*object
+	// dyn is the dynamic state, which is only set on the global scope.
+	dyn *dynamic
+}
+
+// truthy reports whether o is true as a test, by the rule of the interpreter
+// that e belongs to.
+func (e *env) truthy(o *object) bool {
+	if e.dynamic().scheme {
+		return o == nil || o.t != TYPE_BOOL || o.b
+	}
+	return o.isTruthy()
+}
+
+// dynamic returns the dynamic state of the interpreter that e belongs to.
+func (e *env) dynamic() *dynamic {
+	for e.outer != nil {
+		e = e.outer
+	}
+	return e.dyn
 }
 
 // TODO: test
@@ -32,7 +52,7 @@ func (e *env) find(key string) (*env, error) {
 	if e.outer != nil {
 		return e.outer.find(key)
 	}
-	return nil, fmt.Errorf("%q not found", key)
+	return nil, newError(NameError, "%q not found", key)
 }
 
 // define creates a new key in the current scope.
@@ -50,362 +70,493 @@ func (e *env) set(key string, value *object) error {
 	return nil
 }
 
-var globalEnv env = env{
-	outer: nil,
-	m: map[string]*object{
-		// operators
-		"+": newObject(func(o ...*object) (*object, error) {
-			if len(o) == 1 {
-				return nil, errors.New("expected at least two arguments to +")
-			}
-
-			var res float64
-			var isint bool
-			for _, v := range o {
-				isint = isint && (v.t == TYPE_INT)
-				f, err := v.toFloat()
+// newGlobalEnv returns a new outermost scope holding the standard procedures.
+func newGlobalEnv() *env {
+	callcc := newObject(func(o ...*object) (*object, error) {
+		if len(o) != 1 {
+			return nil, errors.New("expected one argument to call/cc")
+		}
+		return callCC(o[0])
+	})
+
+	expt := newObject(func(o ...*object) (*object, error) {
+		if len(o) != 2 {
+			return nil, errors.New("expected two arguments to expt")
+		}
+		return power(o[0], o[1])
+	})
+
+	g := &env{
+		outer: nil,
+		dyn:   &dynamic{},
+		m: map[string]*object{
+			// operators
+			"+": newObject(func(o ...*object) (*object, error) {
+				if len(o) == 1 {
+					return nil, errors.New("expected at least two arguments to +")
+				}
+				if len(o) == 0 {
+					return newObject(0), nil
+				}
+				return addOp.fold(o)
+			}),
+			"-": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to -")
+				}
+				return subOp.apply(o[0], o[1])
+			}),
+			"*": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to *")
+				}
+				return mulOp.apply(o[0], o[1])
+			}),
+			"/": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to /")
+				}
+				return divOp.apply(o[0], o[1])
+			}),
+			">": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to >")
+				}
+				c, err := compare(o[0], o[1])
 				if err != nil {
 					return nil, err
 				}
-				res += f
-			}
-
-			if isint {
-				return newObject(int64(res)), nil
-			}
-			return newObject(res), nil
-		}),
-		"-": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to -")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			res := a - b
-			if o[0].t == TYPE_INT && o[1].t == TYPE_INT {
-				return newObject(int64(res)), nil
-
-			}
-			return newObject(res), nil
-		}),
-		"*": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to *")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			res := a * b
-			if o[0].t == TYPE_INT && o[1].t == TYPE_INT {
-				return newObject(int64(res)), nil
-
-			}
-			return newObject(res), nil
-		}),
-		"/": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to /")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			res := a / b
-			if o[0].t == TYPE_INT && o[1].t == TYPE_INT {
-				return newObject(int64(res)), nil
-
-			}
-			return newObject(res), nil
-		}),
-		">": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to >")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			return newObject(a > b), nil
-		}),
-		"<": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to <")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			return newObject(a < b), nil
-		}),
-		">=": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to >=")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			return newObject(a >= b), nil
-		}),
-		"<=": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to <=")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			return newObject(a <= b), nil
-		}),
-		"=": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to =")
-			}
-
-			a, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			b, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			return newObject(a == b), nil
-		}),
-
-		// math
-		"abs": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to abs")
-			}
-			if o[0].t == TYPE_FLOAT {
-				return newObject(math.Abs(o[0].f)), nil
-			} else if o[0].t == TYPE_INT {
-				return newObject(math.Abs(float64(o[0].i))), nil
-			}
-			return nil, errors.New("expected float or int argument to abs")
-		}),
-		"pow": newObject(func(o ...*object) (*object, error) {
-			f0, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-			f1, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			return newObject(math.Pow(f0, f1)), nil
-		}),
-		"expt": newObject(func(o ...*object) (*object, error) {
-			f0, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-			f1, err := o[1].toFloat()
-			if err != nil {
-				return nil, err
-			}
-
-			return newObject(math.Pow(f0, f1)), nil
-		}),
-		"sqrt": newObject(func(o ...*object) (*object, error) {
-			f, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-			return newObject(math.Sqrt(f)), nil
-		}),
-		"round": newObject(func(o ...*object) (*object, error) {
-			f, err := o[0].toFloat()
-			if err != nil {
-				return nil, err
-			}
-			return newObject(math.Trunc(f)), nil
-		}),
-		"sin": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to sin")
-			}
-			if o[0].t == TYPE_FLOAT {
-				return newObject(math.Sin(o[0].f)), nil
-			} else if o[0].t == TYPE_INT {
-				return newObject(math.Sin(float64(o[0].i))), nil
-			}
-			return nil, errors.New("expected float or int argument to sin")
-		}),
-		"cos": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to cos")
-			}
-			if o[0].t == TYPE_FLOAT {
-				return newObject(math.Cos(o[0].f)), nil
-			} else if o[0].t == TYPE_INT {
-				return newObject(math.Cos(float64(o[0].i))), nil
-			}
-			return nil, errors.New("expected float or int argument to cos")
-		}),
-		"pi": newObject(math.Pi),
+				return newObject(c > 0), nil
+			}),
+			"<": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to <")
+				}
+				c, err := compare(o[0], o[1])
+				if err != nil {
+					return nil, err
+				}
+				return newObject(c < 0), nil
+			}),
+			">=": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to >=")
+				}
+				c, err := compare(o[0], o[1])
+				if err != nil {
+					return nil, err
+				}
+				return newObject(c >= 0), nil
+			}),
+			"<=": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to <=")
+				}
+				c, err := compare(o[0], o[1])
+				if err != nil {
+					return nil, err
+				}
+				return newObject(c <= 0), nil
+			}),
+			"=": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to =")
+				}
+				c, err := compare(o[0], o[1])
+				if err != nil {
+					return nil, err
+				}
+				return newObject(c == 0), nil
+			}),
 
-		"begin": newObject(func(o ...*object) (*object, error) {
-			return newObject(o), nil
-		}),
+			// math
+			"abs": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to abs")
+				}
+				if !isNumber(o[0]) {
+					return nil, errors.New("expected float or int argument to abs")
+				}
+				if c, _ := compare(o[0], newObject(0)); c < 0 {
+					return subOp.apply(newObject(0), o[0])
+				}
+				return o[0], nil
+			}),
+			"pow":  expt,
+			"expt": expt,
+			"sqrt": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to sqrt")
+				}
+				f, err := o[0].toFloat()
+				if err != nil {
+					return nil, err
+				}
+				return newObject(math.Sqrt(f)), nil
+			}),
+			"round": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to round")
+				}
+				f, err := o[0].toFloat()
+				if err != nil {
+					return nil, err
+				}
+				return newObject(math.Trunc(f)), nil
+			}),
+			"sin": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to sin")
+				}
+				if !isNumber(o[0]) {
+					return nil, errors.New("expected float or int argument to sin")
+				}
+				f, _ := o[0].toFloat()
+				return newObject(math.Sin(f)), nil
+			}),
+			"cos": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to cos")
+				}
+				if !isNumber(o[0]) {
+					return nil, errors.New("expected float or int argument to cos")
+				}
+				f, _ := o[0].toFloat()
+				return newObject(math.Cos(f)), nil
+			}),
+			"pi": newObject(math.Pi),
+
+			// list manipulation
+			"car": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to car")
+				}
+				x := toData(o[0])
+				if x == nil || x.t != TYPE_PAIR {
+					return nil, errors.New("expected pair as argument to car")
+				}
+				return x.car, nil
+			}),
+			"cdr": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to cdr")
+				}
+				x := toData(o[0])
+				if x == nil || x.t != TYPE_PAIR {
+					return nil, errors.New("expected pair as argument to cdr")
+				}
+				return x.cdr, nil
+			}),
+			"cons": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to cons")
+				}
+				return cons(o[0], toData(o[1])), nil
+			}),
+			"set-car!": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to set-car!")
+				}
+				if o[0] == nil || o[0].t != TYPE_PAIR {
+					return nil, errors.New("expected pair as first argument to set-car!")
+				}
+				o[0].car = o[1]
+				return nil, nil
+			}),
+			"set-cdr!": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to set-cdr!")
+				}
+				if o[0] == nil || o[0].t != TYPE_PAIR {
+					return nil, errors.New("expected pair as first argument to set-cdr!")
+				}
+				o[0].cdr = o[1]
+				return nil, nil
+			}),
+			"eq?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to eq?")
+				}
+				return newObject(eq(o[0], o[1])), nil
+			}),
+			"equal?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to equal?")
+				}
+				return newObject(equal(o[0], o[1])), nil
+			}),
+			"length": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to len")
+				}
+				l, err := toSlice(o[0])
+				if err != nil {
+					return nil, errors.New("expected list as argument to len")
+				}
+				return newObject(len(l)), nil
+			}),
+			"list": newObject(func(o ...*object) (*object, error) {
+				return list(o...), nil
+			}),
+			"list?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to list?")
+				}
+				return newObject(isList(o[0])), nil
+			}),
+			"pair?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to pair?")
+				}
+				return newObject(o[0] != nil && o[0].t == TYPE_PAIR), nil
+			}),
+			"map": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 {
+					return nil, errors.New("expected two arguments to map")
+				}
+				fn := o[0]
+				if fn == nil || (fn.t != TYPE_FN && fn.t != TYPE_LAMBDA) {
+					return nil, errors.New("expected callable for first argument to map")
+				}
 
-		// list manipulation
-		"car": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to car")
-			}
-			x := o[0]
-			if x.t != TYPE_LIST {
-				return nil, errors.New("expected list as argument to car")
-			}
-			return x.l[0], nil
-		}),
-		"cdr": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to cdr")
-			}
-			x := o[0]
-			if x.t != TYPE_LIST {
-				return nil, errors.New("expected list as argument to cdr")
-			}
-			return newObject(x.l[1:]), nil
-		}),
-		"cons": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 2 {
-				return nil, errors.New("expected two arguments to cons")
-			}
-			if o[1].t != TYPE_LIST {
-				return nil, errors.New("expected list as second argument to cons")
-			}
-			l := append([]*object{o[0]}, o[1].l...)
-			return newObject(l), nil
-		}),
-		"eq?": newObject(func(o ...*object) (*object, error) {
-			return newObject(&o[0] == &o[1]), nil
-		}),
-		"equal?": newObject(func(o ...*object) (*object, error) {
-			return newObject(reflect.DeepEqual(o[0], o[1])), nil
-		}),
-		"length": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to len")
-			}
-			if o[0].t != TYPE_LIST {
-				return nil, errors.New("expected list as argument to len")
-			}
-			return newObject(len(o[0].l)), nil
-		}),
-		"list": newObject(func(o ...*object) (*object, error) {
-			return newObject(o), nil
-		}),
-		"list?": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to list?")
-			}
-			return newObject(o[0].t == TYPE_LIST), nil
-		}),
-		"map": newObject(func(o ...*object) (*object, error) {
-			fn := o[0]
-			if fn.t != TYPE_FN && fn.t != TYPE_LAMBDA {
-				return nil, errors.New("expected callable for first argument to map")
-			}
+				args, err := toSlice(o[1])
+				if err != nil {
+					return nil, errors.New("expected list for second argument to map")
+				}
 
-			args := o[1]
-			if args.t != TYPE_LIST {
-				return nil, errors.New("expected list for second argument to map")
-			}
+				res := []*object{}
+				for _, arg := range args {
+					log.Printf("mapping with arg %+v", arg)
+					r, err := apply(fn, arg)
+					if err != nil {
+						return nil, err
+					}
 
-			res := []*object{}
-			for _, arg := range args.l {
-				var r *object
-				var err error
-				log.Printf("mapping with arg %+v", arg)
-				if fn.t == TYPE_FN {
-					r, err = fn.fn(arg)
-				} else if fn.t == TYPE_LAMBDA {
-					r, err = fn.lambda.call(arg)
+					res = append(res, r)
+				}
+				return list(res...), nil
+			}),
+			"null?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to null?")
 				}
+				return newObject(isEmptyList(o[0])), nil
+			}),
+			"number?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to number?")
+				}
+				return newObject(isNumber(o[0])), nil
+			}),
+			"exact?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 || !isNumber(o[0]) {
+					return nil, errors.New("expected number as argument to exact?")
+				}
+				return newObject(isExact(o[0])), nil
+			}),
+			"inexact?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 || !isNumber(o[0]) {
+					return nil, errors.New("expected number as argument to inexact?")
+				}
+				return newObject(!isExact(o[0])), nil
+			}),
+			"exact->inexact": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 || !isNumber(o[0]) {
+					return nil, errors.New("expected number as argument to exact->inexact")
+				}
+				f, err := o[0].toFloat()
 				if err != nil {
 					return nil, err
 				}
+				return newObject(f), nil
+			}),
+			"inexact->exact": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 || !isNumber(o[0]) {
+					return nil, errors.New("expected number as argument to inexact->exact")
+				}
+				return toExact(o[0])
+			}),
+			"boolean?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to boolean?")
+				}
+				return newObject(o[0] != nil && o[0].t == TYPE_BOOL), nil
+			}),
+			"procedure?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to procedure?")
+				}
+				return newObject(o[0] != nil && (o[0].t == TYPE_FN || o[0].t == TYPE_LAMBDA)), nil
+			}),
+			"symbol?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to symbol?")
+				}
+				return newObject(o[0] != nil && o[0].t == TYPE_SYMBOL), nil
+			}),
 
-				res = append(res, r)
-			}
-			return newObject(res), nil
-		}),
-		"null?": newObject(func(o ...*object) (*object, error) {
-			return newObject(reflect.DeepEqual(o[0], nil)), nil
-		}),
-		"number?": newObject(func(o ...*object) (*object, error) {
-			return newObject(o[0].t == TYPE_INT || o[0].t == TYPE_FLOAT), nil
-		}),
-		"procedure?": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to procedure?")
-			}
-			return newObject(o[0].t == TYPE_FN || o[0].t == TYPE_LAMBDA), nil
-		}),
-		"symbol?": newObject(func(o ...*object) (*object, error) {
-			if len(o) != 1 {
-				return nil, errors.New("expected one argument to symbol?")
-			}
-			return newObject(o[0].t == TYPE_SYMBOL), nil
-		}),
-	},
+			// strings
+			"string?": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to string?")
+				}
+				return newObject(o[0] != nil && o[0].t == TYPE_STRING), nil
+			}),
+			"string-length": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to string-length")
+				}
+				if o[0] == nil || o[0].t != TYPE_STRING {
+					return nil, errors.New("expected string as argument to string-length")
+				}
+				return newObject(utf8.RuneCountInString(o[0].s)), nil
+			}),
+			"string-append": newObject(func(o ...*object) (*object, error) {
+				var b strings.Builder
+				for _, s := range o {
+					if s == nil || s.t != TYPE_STRING {
+						return nil, errors.New("expected string arguments to string-append")
+					}
+					b.WriteString(s.s)
+				}
+				return newString(b.String()), nil
+			}),
+			"substring": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 2 && len(o) != 3 {
+					return nil, errors.New("expected two or three arguments to substring")
+				}
+				if o[0] == nil || o[0].t != TYPE_STRING {
+					return nil, errors.New("expected string as first argument to substring")
+				}
+				r := []rune(o[0].s)
+				start, end := o[1], newObject(len(r))
+				if len(o) == 3 {
+					end = o[2]
+				}
+				if start == nil || end == nil || start.t != TYPE_INT || end.t != TYPE_INT {
+					return nil, errors.New("expected int indices to substring")
+				}
+				if start.i < 0 || start.i > end.i || end.i > int64(len(r)) {
+					return nil, fmt.Errorf("substring indices %d and %d out of range for length %d", start.i, end.i, len(r))
+				}
+				return newString(string(r[start.i:end.i])), nil
+			}),
+			"string-upcase": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to string-upcase")
+				}
+				if o[0] == nil || o[0].t != TYPE_STRING {
+					return nil, errors.New("expected string as argument to string-upcase")
+				}
+				return newString(strings.ToUpper(o[0].s)), nil
+			}),
+			"string-downcase": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to string-downcase")
+				}
+				if o[0] == nil || o[0].t != TYPE_STRING {
+					return nil, errors.New("expected string as argument to string-downcase")
+				}
+				return newString(strings.ToLower(o[0].s)), nil
+			}),
+			"string-split": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 && len(o) != 2 {
+					return nil, errors.New("expected one or two arguments to string-split")
+				}
+				for _, s := range o {
+					if s == nil || s.t != TYPE_STRING {
+						return nil, errors.New("expected string arguments to string-split")
+					}
+				}
+				var parts []string
+				if len(o) == 1 {
+					parts = strings.Fields(o[0].s)
+				} else {
+					parts = strings.Split(o[0].s, o[1].s)
+				}
+				l := make([]*object, len(parts))
+				for i, p := range parts {
+					l[i] = newString(p)
+				}
+				return list(l...), nil
+			}),
+			"string-join": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 && len(o) != 2 {
+					return nil, errors.New("expected one or two arguments to string-join")
+				}
+				l, err := toSlice(o[0])
+				if err != nil {
+					return nil, errors.New("expected list as first argument to string-join")
+				}
+				sep := " "
+				if len(o) == 2 {
+					if o[1] == nil || o[1].t != TYPE_STRING {
+						return nil, errors.New("expected string as second argument to string-join")
+					}
+					sep = o[1].s
+				}
+				parts := make([]string, len(l))
+				for i, s := range l {
+					if s == nil || s.t != TYPE_STRING {
+						return nil, errors.New("expected list of strings as first argument to string-join")
+					}
+					parts[i] = s.s
+				}
+				return newString(strings.Join(parts, sep)), nil
+			}),
+			"string->symbol": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to string->symbol")
+				}
+				if o[0] == nil || o[0].t != TYPE_STRING {
+					return nil, errors.New("expected string as argument to string->symbol")
+				}
+				return newObject(o[0].s), nil
+			}),
+			"symbol->string": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to symbol->string")
+				}
+				if o[0] == nil || (o[0].t != TYPE_SYMBOL && o[0].t != TYPE_BUILTIN) {
+					return nil, errors.New("expected symbol as argument to symbol->string")
+				}
+				return newString(o[0].s), nil
+			}),
+			"string->number": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to string->number")
+				}
+				if o[0] == nil || o[0].t != TYPE_STRING {
+					return nil, errors.New("expected string as argument to string->number")
+				}
+				if n, err := parseNumber(o[0].s); n != nil && err == nil {
+					return n, nil
+				}
+				return newObject(false), nil
+			}),
+			"number->string": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 1 {
+					return nil, errors.New("expected one argument to number->string")
+				}
+				if !isNumber(o[0]) {
+					return nil, errors.New("expected number as argument to number->string")
+				}
+				return newString(o[0].String()), nil
+			}),
+
+			// control
+			"call-with-current-continuation": callcc,
+			"call/cc":                        callcc,
+			"dynamic-wind": newObject(func(o ...*object) (*object, error) {
+				if len(o) != 3 {
+					return nil, errors.New("expected three arguments to dynamic-wind")
+				}
+				return dynamicWind(o[0], o[1], o[2])
+			}),
+		},
+	}
+	for k, v := range exceptionBuiltins(g.dyn) {
+		g.define(k, v)
+	}
+	return g
 }
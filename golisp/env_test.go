@@ -36,7 +36,7 @@ func TestGet(t *testing.T) {
 		},
 		{
 			key:     "baz",
-			wantErr: fmt.Errorf("%q not found", "baz"),
+			wantErr: &Error{Kind: NameError, Err: fmt.Errorf("%q not found", "baz")},
 		},
 	}
 
@@ -80,7 +80,7 @@ func TestFind(t *testing.T) {
 		},
 		{
 			key:     "baz",
-			wantErr: fmt.Errorf("%q not found", "baz"),
+			wantErr: &Error{Kind: NameError, Err: fmt.Errorf("%q not found", "baz")},
 		},
 	}
 
@@ -110,7 +110,7 @@ func TestGlobalEnv(t *testing.T) {
 		{
 			key:     "+",
 			args:    []*object{newObject(4)},
-			wantErr: errors.New("expected two arguments to +"),
+			wantErr: errors.New("expected at least two arguments to +"),
 		},
 		{
 			key:  "-",
@@ -230,7 +230,7 @@ func TestGlobalEnv(t *testing.T) {
 		{
 			key:  "abs",
 			args: []*object{newObject(-42)},
-			want: newObject(42.0),
+			want: newObject(42),
 		},
 		{
 			key:  "abs",
@@ -304,12 +304,12 @@ func TestGlobalEnv(t *testing.T) {
 		{
 			key:     "car",
 			args:    []*object{newObject(4)},
-			wantErr: errors.New("expected list as argument to car"),
+			wantErr: errors.New("expected pair as argument to car"),
 		},
 		{
 			key:  "cdr",
 			args: []*object{newObject([]*object{newObject("foo"), newObject("bar")})},
-			want: newObject([]*object{newObject("bar")}),
+			want: list(newObject("bar")),
 		},
 		{
 			key:     "cdr",
@@ -319,7 +319,7 @@ func TestGlobalEnv(t *testing.T) {
 		{
 			key:     "cdr",
 			args:    []*object{newObject(4)},
-			wantErr: errors.New("expected list as argument to cdr"),
+			wantErr: errors.New("expected pair as argument to cdr"),
 		},
 		{
 			key: "cons",
@@ -327,7 +327,7 @@ func TestGlobalEnv(t *testing.T) {
 				newObject("baz"),
 				newObject([]*object{newObject("foo"), newObject("bar")}),
 			},
-			want: newObject([]*object{newObject("baz"), newObject("foo"), newObject("bar")}),
+			want: list(newObject("baz"), newObject("foo"), newObject("bar")),
 		},
 		{
 			key: "cons",
@@ -335,7 +335,7 @@ func TestGlobalEnv(t *testing.T) {
 				newObject(42),
 				newObject([]*object{newObject("foo"), newObject("bar")}),
 			},
-			want: newObject([]*object{newObject(42), newObject("foo"), newObject("bar")}),
+			want: list(newObject(42), newObject("foo"), newObject("bar")),
 		},
 		{
 			key:     "cons",
@@ -362,12 +362,12 @@ func TestGlobalEnv(t *testing.T) {
 		{
 			key:  "list",
 			args: []*object{newObject(42)},
-			want: newObject([]*object{newObject(42)}),
+			want: list(newObject(42)),
 		},
 		{
 			key:  "list",
 			args: []*object{newObject(42), newObject("foo"), newObject(64)},
-			want: newObject([]*object{newObject(42), newObject("foo"), newObject(64)}),
+			want: list(newObject(42), newObject("foo"), newObject(64)),
 		},
 		{
 			key:     "list?",
@@ -394,9 +394,24 @@ func TestGlobalEnv(t *testing.T) {
 					newObject(0), newObject(1), newObject(2),
 				}),
 			},
-			want: newObject([]*object{
+			want: list(
 				newObject(0), newObject(2), newObject(4),
-			}),
+			),
+		},
+		{
+			key:     "map",
+			args:    []*object{nil, list(newObject(1))},
+			wantErr: errors.New("expected callable for first argument to map"),
+		},
+		{
+			key:     "map",
+			args:    []*object{},
+			wantErr: errors.New("expected two arguments to map"),
+		},
+		{
+			key:     "sqrt",
+			args:    []*object{},
+			wantErr: errors.New("expected one argument to sqrt"),
 		},
 		{
 			key:     "procedure?",
@@ -415,7 +430,7 @@ func TestGlobalEnv(t *testing.T) {
 		},
 		{
 			key:  "procedure?",
-			args: []*object{newObject(&lambda{newObject(42), newObject(64), nil})},
+			args: []*object{newObject(&lambda{params: newObject(42), body: newObject(64)})},
 			want: newObject(true),
 		},
 		{
@@ -433,10 +448,136 @@ func TestGlobalEnv(t *testing.T) {
 			args: []*object{newObject(42)},
 			want: newObject(false),
 		},
+		{
+			key:  "string?",
+			args: []*object{newString("foo")},
+			want: newObject(true),
+		},
+		{
+			key:  "string?",
+			args: []*object{newObject("foo")},
+			want: newObject(false),
+		},
+		{
+			key:  "string?",
+			args: []*object{nil},
+			want: newObject(false),
+		},
+		{
+			key:  "string-length",
+			args: []*object{newString("héllo")},
+			want: newObject(5),
+		},
+		{
+			key:     "string-length",
+			args:    []*object{newObject(42)},
+			wantErr: errors.New("expected string as argument to string-length"),
+		},
+		{
+			key:  "string-append",
+			args: []*object{newString("foo"), newString(""), newString("bar")},
+			want: newString("foobar"),
+		},
+		{
+			key:     "string-append",
+			args:    []*object{newString("foo"), newObject("bar")},
+			wantErr: errors.New("expected string arguments to string-append"),
+		},
+		{
+			key:     "string-append",
+			args:    []*object{newString("a"), nil},
+			wantErr: errors.New("expected string arguments to string-append"),
+		},
+		{
+			key:  "substring",
+			args: []*object{newString("héllo"), newObject(1), newObject(3)},
+			want: newString("él"),
+		},
+		{
+			key:  "substring",
+			args: []*object{newString("hello"), newObject(3)},
+			want: newString("lo"),
+		},
+		{
+			key:     "substring",
+			args:    []*object{newString("hello"), newObject(3), newObject(9)},
+			wantErr: errors.New("substring indices 3 and 9 out of range for length 5"),
+		},
+		{
+			key:  "string-upcase",
+			args: []*object{newString("Foo")},
+			want: newString("FOO"),
+		},
+		{
+			key:  "string-downcase",
+			args: []*object{newString("Foo")},
+			want: newString("foo"),
+		},
+		{
+			key:  "string-split",
+			args: []*object{newString(" a  b\tc ")},
+			want: list(newString("a"), newString("b"), newString("c")),
+		},
+		{
+			key:  "string-split",
+			args: []*object{newString("a,,b"), newString(",")},
+			want: list(newString("a"), newString(""), newString("b")),
+		},
+		{
+			key:  "string-join",
+			args: []*object{newObject([]*object{newString("a"), newString("b")}), newString(", ")},
+			want: newString("a, b"),
+		},
+		{
+			key:     "string-join",
+			args:    []*object{newObject([]*object{newString("a"), newObject(1)})},
+			wantErr: errors.New("expected list of strings as first argument to string-join"),
+		},
+		{
+			key:  "string->symbol",
+			args: []*object{newString("foo")},
+			want: newObject("foo"),
+		},
+		{
+			key:  "symbol->string",
+			args: []*object{newObject("foo")},
+			want: newString("foo"),
+		},
+		{
+			key:  "string->number",
+			args: []*object{newString("42")},
+			want: newObject(42),
+		},
+		{
+			key:  "string->number",
+			args: []*object{newString("4.5")},
+			want: newObject(4.5),
+		},
+		{
+			key:  "string->number",
+			args: []*object{newString("four")},
+			want: newObject(false),
+		},
+		{
+			key:  "number->string",
+			args: []*object{newObject(42)},
+			want: newString("42"),
+		},
+		{
+			key:  "number->string",
+			args: []*object{newObject(1e-10)},
+			want: newString("1e-10"),
+		},
+		{
+			key:     "number->string",
+			args:    []*object{newString("42")},
+			wantErr: errors.New("expected number as argument to number->string"),
+		},
 	}
 
+	e := newGlobalEnv()
 	for _, tt := range cases {
-		o, ok := globalEnv.m[tt.key]
+		o, ok := e.m[tt.key]
 		if !ok {
 			t.Fatalf("key %q not found", tt.key)
 		}
@@ -460,3 +601,14 @@ func TestGlobalEnv(t *testing.T) {
 		}
 	}
 }
+
+func TestNumberStringRoundTrip(t *testing.T) {
+	var cases []evalTest
+	for _, n := range []string{"1e-10", "(exact->inexact 1/3)", "0.1", "1e100", "2.0", "7/2", "(expt 2 70)"} {
+		cases = append(cases, evalTest{
+			program: fmt.Sprintf("(define n %s) (define m (string->number (number->string n))) (list (= m n) (equal? (exact? m) (exact? n)))", n),
+			want:    "(#t #t)",
+		})
+	}
+	runEvalTests(t, cases)
+}
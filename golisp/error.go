@@ -0,0 +1,125 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorKind classifies an Error.
+type ErrorKind int
+
+const (
+	// RuntimeError is any failure while evaluating a well formed program.
+	RuntimeError ErrorKind = iota
+	// SyntaxError is a failure to read a program.
+	SyntaxError
+	// NameError is a reference to a symbol that is not defined.
+	NameError
+	// ArityError is a call with the wrong number of arguments.
+	ArityError
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case SyntaxError:
+		return "syntax error"
+	case NameError:
+		return "name error"
+	case ArityError:
+		return "arity error"
+	}
+	return "runtime error"
+}
+
+// errEOF is wrapped by syntax errors caused by the source ending early.
+var errEOF = errors.New("unexpected EOF")
+
+// Error is an error reading or evaluating a program.
+type Error struct {
+	Kind ErrorKind
+	// Span is where in the source the error occurred. It is the zero Span if
+	// the location is unknown.
+	Span Span
+	// Form is the printed form that failed, if any.
+	Form string
+	Err  error
+}
+
+// Error returns the message prefixed with the location, if known.
+func (e *Error) Error() string {
+	if e.Span.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Span, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Render returns the message followed by the offending source line with the
+// span underlined, or just the message if the source is not known.
+func (e *Error) Render() string {
+	line, ok := e.Span.line()
+	if !ok {
+		return e.Error()
+	}
+
+	// Copy the tabs so that the carets line up however they are expanded.
+	var indent strings.Builder
+	for i, r := range []rune(line) {
+		if i >= e.Span.Col-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	// Underline the rest of the span if it is on the same line.
+	width := 0
+	if e.Span.EndLine == e.Span.Line && e.Span.EndCol-e.Span.Col > 1 {
+		width = e.Span.EndCol - e.Span.Col - 1
+	}
+	return fmt.Sprintf("%s\n\t%s\n\t%s^%s", e.Error(), line, indent.String(), strings.Repeat("~", width))
+}
+
+// newError returns an Error of kind k with no location.
+func newError(k ErrorKind, format string, a ...interface{}) *Error {
+	return &Error{Kind: k, Err: fmt.Errorf(format, a...)}
+}
+
+// syntaxError returns a SyntaxError at span s.
+func syntaxError(s Span, err error) *Error {
+	return &Error{Kind: SyntaxError, Span: s, Err: err}
+}
+
+// errorAt locates err at the form x. Errors that already have a location
+// are returned as is so that the innermost form is reported.
+func errorAt(x *object, err error) error {
+	if _, ok := err.(*unwind); ok {
+		return err
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Kind: RuntimeError, Err: err}
+	}
+	if e.Span.Line != 0 || x == nil || x.span == nil {
+		return e
+	}
+	e.Span = *x.span
+	e.Form = x.String()
+	return e
+}
+
+// RenderError returns err rendered with its source line if it is an Error,
+// and its message otherwise.
+func RenderError(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Render()
+	}
+	return err.Error()
+}
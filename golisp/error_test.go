@@ -0,0 +1,92 @@
+package golisp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorLocation(t *testing.T) {
+	i := New()
+	if _, err := i.Eval("(define f (lambda (x)\n\t(+ x y)))"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		program    string
+		wantKind   ErrorKind
+		wantForm   string
+		wantRender string
+	}{
+		{
+			program:    "(f 1)",
+			wantKind:   NameError,
+			wantForm:   "y",
+			wantRender: "2:7: \"y\" not found\n\t\t(+ x y)))\n\t\t     ^",
+		},
+		{
+			program:    "(f 1 2)",
+			wantKind:   ArityError,
+			wantForm:   "(f 1 2)",
+			wantRender: "1:1: expected one argument to f, got 2\n\t(f 1 2)\n\t^~~~~~~",
+		},
+		{
+			program:    "(list 1\n  (car 2))",
+			wantKind:   RuntimeError,
+			wantForm:   "(car 2)",
+			wantRender: "2:3: expected pair as argument to car\n\t  (car 2))\n\t  ^~~~~~~",
+		},
+		{
+			program:    "((begin) 1)",
+			wantKind:   RuntimeError,
+			wantForm:   "((begin) 1)",
+			wantRender: "1:1: expected lambda or fn\n\t((begin) 1)\n\t^~~~~~~~~~~",
+		},
+		{
+			program:    "(list 1))",
+			wantKind:   SyntaxError,
+			wantRender: "1:9: unexpected ')'\n\t(list 1))\n\t        ^",
+		},
+	}
+
+	for _, tt := range cases {
+		_, err := i.Eval(tt.program)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%s: got %#v, want *Error", tt.program, err)
+		}
+		if e.Kind != tt.wantKind {
+			t.Errorf("%s: got kind %s, want %s", tt.program, e.Kind, tt.wantKind)
+		}
+		if e.Form != tt.wantForm {
+			t.Errorf("%s: got form %q, want %q", tt.program, e.Form, tt.wantForm)
+		}
+		if got := RenderError(err); got != tt.wantRender {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.program, got, tt.wantRender)
+		}
+	}
+}
+
+func TestErrorEOF(t *testing.T) {
+	for _, program := range []string{"(+ 1", "1 (+ 2", "\"abc", "#| abc"} {
+		_, err := New().Eval(program)
+		if !errors.Is(err, errEOF) {
+			t.Errorf("%q: got err %q, want unexpected EOF", program, err)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{err: errors.New("plain"), want: "plain"},
+		{err: newError(NameError, "no location"), want: "no location"},
+		{err: &Error{Span: Span{File: "f.lisp", Line: 3, Col: 2}, Err: errors.New("no source")}, want: "f.lisp:3:2: no source"},
+	}
+	for _, tt := range cases {
+		if got := RenderError(tt.err); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// condition is the error made by the error procedure: a message and the
+// objects it concerns.
+type condition struct {
+	msg       string
+	irritants []*object
+}
+
+func (c *condition) Error() string {
+	ss := []string{c.msg}
+	for _, o := range c.irritants {
+		ss = append(ss, o.String())
+	}
+	return strings.Join(ss, " ")
+}
+
+// raised is the error by which raise passes an object that is not an error
+// object to a handler.
+type raised struct {
+	v *object
+}
+
+func (r *raised) Error() string {
+	return fmt.Sprintf("uncaught exception: %s", r.v)
+}
+
+// handled is an error raised by the handler called by a raise-continuable,
+// which is passed on to the handlers outside of frame.
+type handled struct {
+	frame *handlerFrame
+	err   error
+}
+
+func (h *handled) Error() string {
+	return h.err.Error()
+}
+
+func (h *handled) Unwrap() error {
+	return h.err
+}
+
+// handlerFrame is an exception handler installed by with-exception-handler,
+// or by a guard if proc is nil.
+type handlerFrame struct {
+	proc *object
+}
+
+// dynamic is the state of an interpreter shared by all of its scopes: the
+// dynamic extent of the evaluation and the rules of its dialect.
+type dynamic struct {
+	handlers []*handlerFrame
+	// scheme is set if only #f is false, rather than any value for which
+	// isTruthy is false.
+	scheme bool
+}
+
+// conditionOf returns the object raised by err. Errors other than those of
+// raise are error objects.
+func conditionOf(err error) *object {
+	var r *raised
+	if errors.As(err, &r) {
+		return r.v
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Kind: RuntimeError, Err: err}
+	}
+	return newObject(e)
+}
+
+// isControl returns true if err is transferring control rather than failing,
+// so must not be caught by handlers.
+func isControl(err error) bool {
+	_, ok := err.(*unwind)
+	return ok
+}
+
+// raise returns the error that raises o. Error objects are raised as the
+// errors they were made from.
+func raise(o *object) error {
+	if o != nil && o.t == TYPE_ERROR {
+		return o.err
+	}
+	return &raised{o}
+}
+
+// push returns the handlers with f installed innermost. The handlers are
+// copied, so that the slice they are restored from is left unchanged.
+func (d *dynamic) push(f *handlerFrame) []*handlerFrame {
+	n := len(d.handlers)
+	return append(d.handlers[:n:n], f)
+}
+
+// withExceptionHandler calls thunk with handler installed. If thunk fails,
+// handler is called with the condition, with the handlers outside this one
+// installed. handler must not return for a non-continuable condition.
+func (d *dynamic) withExceptionHandler(handler, thunk *object) (*object, error) {
+	f := &handlerFrame{handler}
+	saved := d.handlers
+	d.handlers = d.push(f)
+	res, err := apply(thunk)
+	d.handlers = saved
+	if err == nil || isControl(err) {
+		return res, err
+	}
+
+	var h *handled
+	if errors.As(err, &h) {
+		if h.frame == f {
+			return nil, h.err
+		}
+		return nil, err
+	}
+
+	c := conditionOf(err)
+	if _, err := apply(handler, c); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("handler returned from non-continuable exception: %s", err)
+}
+
+// raiseContinuable calls the current handler with o, with the handlers
+// outside it installed, and returns its value. If the current handler is a
+// guard, o is raised to it.
+func (d *dynamic) raiseContinuable(o *object) (*object, error) {
+	n := len(d.handlers)
+	if n == 0 || d.handlers[n-1].proc == nil {
+		return nil, raise(o)
+	}
+
+	f, saved := d.handlers[n-1], d.handlers
+	// Cap the popped slice so that handlers installed by f copy it rather
+	// than overwriting f in the saved one.
+	d.handlers = d.handlers[: n-1 : n-1]
+	res, err := apply(f.proc, o)
+	d.handlers = saved
+	if err != nil && !isControl(err) {
+		return nil, &handled{f, err}
+	}
+	return res, err
+}
+
+// evalGuard evaluates the body of a guard form x and returns its value, or if
+// it raises a condition returns a cond of the clauses to evaluate in tail
+// position with var bound to the condition, and the scope in which to
+// evaluate it. The condition is raised again if no clause matches.
+//
+//	(guard (var clause ...) body ...)
+func evalGuard(e *env, x *object) (*env, *object, error) {
+	if err := checkForm(x, 2, -1); err != nil {
+		return nil, nil, err
+	}
+	spec := x.l[1]
+	if spec.t != TYPE_LIST || len(spec.l) == 0 || spec.l[0].t != TYPE_SYMBOL {
+		return nil, nil, fmt.Errorf("expected (var clause ...) in guard, got %s", spec)
+	}
+
+	d := e.dynamic()
+	saved := d.handlers
+	d.handlers = d.push(&handlerFrame{})
+	res, err := eval(newScope(e), sequence(x.l[2:]))
+	d.handlers = saved
+	if err == nil {
+		return e, quoted(res), nil
+	}
+	var h *handled
+	if isControl(err) || errors.As(err, &h) {
+		return nil, nil, err
+	}
+
+	inner := newScope(e)
+	inner.define(spec.l[0].s, conditionOf(err))
+	clauses := spec.l[1:]
+	if len(clauses) == 0 || len(clauses[len(clauses)-1].l) == 0 || !isElse(clauses[len(clauses)-1].l[0]) {
+		reraise := newObject(func(o ...*object) (*object, error) {
+			return nil, err
+		})
+		clauses = append(clauses, newObject([]*object{newObject("else"), newObject([]*object{reraise})}))
+	}
+	return inner, newObject(append([]*object{newObject("cond")}, clauses...)), nil
+}
+
+// errorObject returns the Error of the error object o.
+func errorObject(name string, o []*object) (*Error, error) {
+	if len(o) != 1 {
+		return nil, fmt.Errorf("expected one argument to %s", name)
+	}
+	if o[0] == nil || o[0].t != TYPE_ERROR {
+		return nil, fmt.Errorf("expected error object as argument to %s", name)
+	}
+	return o[0].err, nil
+}
+
+// exceptionBuiltins returns the procedures for raising and handling
+// exceptions, which use the dynamic state d.
+func exceptionBuiltins(d *dynamic) map[string]*object {
+	return map[string]*object{
+		"error": newObject(func(o ...*object) (*object, error) {
+			if len(o) == 0 || o[0] == nil || o[0].t != TYPE_STRING {
+				return nil, errors.New("expected message string as first argument to error")
+			}
+			return nil, &condition{o[0].s, o[1:]}
+		}),
+		"raise": newObject(func(o ...*object) (*object, error) {
+			if len(o) != 1 {
+				return nil, errors.New("expected one argument to raise")
+			}
+			return nil, raise(o[0])
+		}),
+		"raise-continuable": newObject(func(o ...*object) (*object, error) {
+			if len(o) != 1 {
+				return nil, errors.New("expected one argument to raise-continuable")
+			}
+			return d.raiseContinuable(o[0])
+		}),
+		"with-exception-handler": newObject(func(o ...*object) (*object, error) {
+			if len(o) != 2 {
+				return nil, errors.New("expected two arguments to with-exception-handler")
+			}
+			return d.withExceptionHandler(o[0], o[1])
+		}),
+		"error-object?": newObject(func(o ...*object) (*object, error) {
+			if len(o) != 1 {
+				return nil, errors.New("expected one argument to error-object?")
+			}
+			return newObject(o[0] != nil && o[0].t == TYPE_ERROR), nil
+		}),
+		"error-object-message": newObject(func(o ...*object) (*object, error) {
+			e, err := errorObject("error-object-message", o)
+			if err != nil {
+				return nil, err
+			}
+			var c *condition
+			if errors.As(e, &c) {
+				return newString(c.msg), nil
+			}
+			return newString(e.Err.Error()), nil
+		}),
+		"error-object-irritants": newObject(func(o ...*object) (*object, error) {
+			e, err := errorObject("error-object-irritants", o)
+			if err != nil {
+				return nil, err
+			}
+			var c *condition
+			if errors.As(e, &c) {
+				return list(c.irritants...), nil
+			}
+			return emptyList(), nil
+		}),
+		"error-object-location": newObject(func(o ...*object) (*object, error) {
+			e, err := errorObject("error-object-location", o)
+			if err != nil {
+				return nil, err
+			}
+			if e.Span.Line == 0 {
+				return newString(""), nil
+			}
+			return newString(e.Span.String()), nil
+		}),
+	}
+}
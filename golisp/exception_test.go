@@ -0,0 +1,138 @@
+package golisp
+
+import "testing"
+
+func TestExceptions(t *testing.T) {
+	cases := []evalTest{
+		{
+			name:    "error",
+			program: "(error \"bad thing:\" 1 'two)",
+			wantErr: "1:1: bad thing: 1 two",
+		},
+		{
+			name:    "uncaught raise",
+			program: "(raise 42)",
+			wantErr: "1:1: uncaught exception: 42",
+		},
+		{
+			name:    "guard raise",
+			program: "(guard (e ((symbol? e) (list 'caught e))) (raise 'oops))",
+			want:    "(caught oops)",
+		},
+		{
+			name:    "guard else",
+			program: "(guard (e ((symbol? e) 1) (else 2)) (raise 42))",
+			want:    "2",
+		},
+		{
+			name:    "guard arrow",
+			program: "(guard (e ((and (string? e) e) => string-length)) (raise \"four\"))",
+			want:    "4",
+		},
+		{
+			name:    "guard no raise",
+			program: "(guard (e (else 0)) 1 2)",
+			want:    "2",
+		},
+		{
+			name:    "guard reraise",
+			program: "(guard (e ((string? e) 1)) (raise 42))",
+			wantErr: "uncaught exception: 42",
+		},
+		{
+			name:    "guard nested",
+			program: "(guard (outer (else (list 'outer outer))) (guard (inner ((string? inner) 1)) (raise 42)))",
+			want:    "(outer 42)",
+		},
+		{
+			name: "error object",
+			program: `(guard (e ((error-object? e) (list (error-object-message e) (error-object-irritants e))))
+					(error "bad thing:" 1 2))`,
+			want: `("bad thing:" (1 2))`,
+		},
+		{
+			name: "go error",
+			program: `(guard (e ((error-object? e) (list (error-object-message e) (error-object-location e))))
+					(+ 1
+					   (car 2)))`,
+			want: `("expected pair as argument to car" "3:9")`,
+		},
+		{
+			name:    "raise error object",
+			program: "(define saved 0) (guard (e (else (set! saved e))) (car 1)) (raise saved)",
+			wantErr: "1:51: expected pair as argument to car",
+		},
+		{
+			name: "with-exception-handler escape",
+			program: `(call/cc (lambda (k)
+					(with-exception-handler
+						(lambda (e) (k (list 'handled e)))
+						(lambda () (+ 1 (raise 'boom))))))`,
+			want: "(handled boom)",
+		},
+		{
+			name: "with-exception-handler go error",
+			program: `(call/cc (lambda (k)
+					(with-exception-handler
+						(lambda (e) (k (error-object-message e)))
+						(lambda () (car 1)))))`,
+			want: `"expected pair as argument to car"`,
+		},
+		{
+			name: "raise-continuable",
+			program: `(with-exception-handler
+					(lambda (c) 42)
+					(lambda () (+ (raise-continuable 'c) 23)))`,
+			want: "65",
+		},
+		{
+			name: "handler returns",
+			program: `(with-exception-handler
+					(lambda (e) 0)
+					(lambda () (raise 'boom)))`,
+			wantErr: "handler returned from non-continuable exception",
+		},
+		{
+			name: "outer handler",
+			program: `(with-exception-handler
+					(lambda (e) (+ e 1))
+					(lambda ()
+						(with-exception-handler
+							(lambda (e) (raise-continuable (* e 10)))
+							(lambda () (raise-continuable 4)))))`,
+			want: "41",
+		},
+		{
+			name: "nested handlers",
+			program: `(with-exception-handler
+					(lambda (e) 'h1)
+					(lambda ()
+						(with-exception-handler
+							(lambda (e) (with-exception-handler (lambda (e) 'h3) (lambda () e)))
+							(lambda ()
+								(raise-continuable 'first)
+								(raise-continuable 'second)))))`,
+			want: "second",
+		},
+		{
+			name: "handler error",
+			program: `(guard (e (else (list 'outer e)))
+					(with-exception-handler
+						(lambda (e) (raise 'again))
+						(lambda () (raise-continuable 'first))))`,
+			want: "(outer again)",
+		},
+		{
+			name:    "guard continuable",
+			program: "(guard (e (else (* e 2))) (raise-continuable 21))",
+			want:    "42",
+		},
+		{
+			name:    "error message",
+			program: "(error 1)",
+			wantErr: "expected message string as first argument to error",
+		},
+	}
+
+	runEvalTests(t, cases)
+}
@@ -0,0 +1,159 @@
+package golisp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Define binds name to the Lisp conversion of value in the global
+// environment. Functions are wrapped as with DefineFunc.
+func (i *Interpreter) Define(name string, value interface{}) error {
+	var o *object
+	var err error
+	if reflect.ValueOf(value).Kind() == reflect.Func {
+		o, err = wrapFunc(name, value)
+	} else {
+		o, err = fromGo(reflect.ValueOf(value))
+	}
+	if err != nil {
+		return err
+	}
+	i.env.define(name, o)
+	return nil
+}
+
+// DefineFunc binds name to a procedure that calls fn. A fn of type
+// func(...Value) (Value, error) receives its arguments unconverted. Any other
+// function has its arguments checked against its signature and converted as
+// by ToGo, and its results converted as by FromGo. It may return at most one
+// value, optionally followed by an error.
+func (i *Interpreter) DefineFunc(name string, fn interface{}) error {
+	o, err := wrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	i.env.define(name, o)
+	return nil
+}
+
+// wrapFunc returns a procedure object that calls fn.
+func wrapFunc(name string, fn interface{}) (*object, error) {
+	switch fn := fn.(type) {
+	case func(...*object) (*object, error):
+		return newObject(fn), nil
+	case func(...Value) (Value, error):
+		return newObject(func(o ...*object) (*object, error) {
+			args := make([]Value, len(o))
+			for i := range o {
+				args[i] = Value{o[i]}
+			}
+			res, err := fn(args...)
+			return res.o, err
+		}), nil
+	}
+
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("expected function for %s", name)
+	}
+	t := v.Type()
+	switch {
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("too many results from %s", name)
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("expected error as second result from %s", name)
+	}
+
+	return newObject(func(o ...*object) (*object, error) {
+		args, err := convertArgs(name, t, o)
+		if err != nil {
+			return nil, err
+		}
+
+		out := v.Call(args)
+		if len(out) != 0 && out[len(out)-1].Type() == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nil, err
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return fromGo(out[0])
+	}), nil
+}
+
+// convertArgs checks the number of args against the signature t and converts
+// each to the type of its parameter.
+func convertArgs(name string, t reflect.Type, o []*object) ([]reflect.Value, error) {
+	n := t.NumIn()
+	if t.IsVariadic() {
+		if len(o) < n-1 {
+			return nil, newError(ArityError, "expected at least %s to %s", numArgs(n-1), name)
+		}
+	} else if len(o) != n {
+		return nil, newError(ArityError, "expected %s to %s", numArgs(n), name)
+	}
+
+	args := make([]reflect.Value, len(o))
+	for i := range o {
+		var pt reflect.Type
+		if t.IsVariadic() && i >= n-1 {
+			pt = t.In(n - 1).Elem()
+		} else {
+			pt = t.In(i)
+		}
+		a, err := toGo(o[i], pt)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to %s: %s", i+1, name, err)
+		}
+		args[i] = a
+	}
+	return args, nil
+}
+
+// numArgs returns a count of arguments for use in error messages.
+func numArgs(n int) string {
+	switch n {
+	case 0:
+		return "no arguments"
+	case 1:
+		return "one argument"
+	case 2:
+		return "two arguments"
+	case 3:
+		return "three arguments"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// Call calls the procedure bound to name with args converted as by FromGo.
+func (i *Interpreter) Call(name string, args ...interface{}) (Value, error) {
+	proc, err := i.env.get(name)
+	if err != nil {
+		return Value{}, err
+	}
+	return i.Apply(Value{proc}, args...)
+}
+
+// Apply calls the procedure proc with args converted as by FromGo.
+func (i *Interpreter) Apply(proc Value, args ...interface{}) (Value, error) {
+	if !proc.IsProcedure() {
+		return Value{}, fmt.Errorf("%s is not a procedure", proc.typeName())
+	}
+	o := make([]*object, len(args))
+	for n, a := range args {
+		var err error
+		if o[n], err = fromGo(reflect.ValueOf(a)); err != nil {
+			return Value{}, fmt.Errorf("argument %d: %s", n+1, err)
+		}
+	}
+	res, err := apply(proc.o, o...)
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{res}, nil
+}
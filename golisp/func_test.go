@@ -0,0 +1,179 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefineFunc(t *testing.T) {
+	i := New()
+	funcs := map[string]interface{}{
+		"native": func(o ...*object) (*object, error) {
+			return newObject(len(o)), nil
+		},
+		"value": func(v ...Value) (Value, error) {
+			return v[len(v)-1], nil
+		},
+		"scale": func(a int, b float64) float64 {
+			return float64(a) * b
+		},
+		"div": func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		},
+		"join": func(sep string, s ...string) string {
+			return strings.Join(s, sep)
+		},
+		"noop": func() {},
+		"pick": func(b bool, x int) int {
+			if b {
+				return x
+			}
+			return 0
+		},
+		"sum": func(l []int) int {
+			s := 0
+			for _, v := range l {
+				s += v
+			}
+			return s
+		},
+	}
+	for name, fn := range funcs {
+		if err := i.DefineFunc(name, fn); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	cases := []struct {
+		program string
+		want    string
+		wantErr error
+	}{
+		{program: "(native 1 2 3)", want: "3"},
+		{program: "(value 1 2 3)", want: "3"},
+		{program: "(scale 2 1.5)", want: "3.0"},
+		{program: "(scale 2)", wantErr: errors.New("1:1: expected two arguments to scale")},
+		{program: "(scale 2.5 1)", wantErr: errors.New("1:1: argument 1 to scale: cannot convert float to int")},
+		{program: "(div 7 2)", want: "3"},
+		{program: "(div 7 0)", wantErr: errors.New("1:1: division by zero")},
+		{program: `(join "-" "a" (quote b))`, want: `"a-b"`},
+		{program: "(join)", wantErr: errors.New("1:1: expected at least one argument to join")},
+		{program: "(noop)", want: ""},
+		{program: "(noop 1)", wantErr: errors.New("1:1: expected no arguments to noop")},
+		{program: "(pick #t 3)", want: "3"},
+		{program: "(pick 5 3)", wantErr: errors.New("1:1: argument 1 to pick: cannot convert int to bool")},
+		{program: `(pick "x" 3)`, wantErr: errors.New("1:1: argument 1 to pick: cannot convert string to bool")},
+		{program: "(sum (list 1 2 3))", want: "6"},
+		{program: "(map (lambda (x) (div x 2)) (list 2 4))", want: "(1 2)"},
+	}
+
+	for _, tt := range cases {
+		got, err := i.Eval(tt.program)
+		if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
+			t.Errorf("%s: got err %q, want err %q", tt.program, err, tt.wantErr)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.program, got, tt.want)
+		}
+	}
+}
+
+func TestDefineFuncErrors(t *testing.T) {
+	cases := []struct {
+		fn      interface{}
+		wantErr error
+	}{
+		{fn: 42, wantErr: errors.New("expected function for f")},
+		{fn: func() (int, int) { return 0, 0 }, wantErr: errors.New("expected error as second result from f")},
+		{fn: func() (int, int, error) { return 0, 0, nil }, wantErr: errors.New("too many results from f")},
+	}
+
+	for _, tt := range cases {
+		if err := New().DefineFunc("f", tt.fn); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("got err %q, want err %q", err, tt.wantErr)
+		}
+	}
+}
+
+func TestDefine(t *testing.T) {
+	i := New()
+	if err := i.Define("answer", 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Define("origin", point{Label: "o"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Define("double", func(x int) int { return 2 * x }); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Define("bad", make(chan int)); err == nil {
+		t.Error("expected error defining a channel")
+	}
+
+	got, err := i.Eval("(list (double answer) origin)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `(84 ((X 0) (Y 0) (Label "o")))`; got.String() != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCall(t *testing.T) {
+	i := New()
+	if _, err := i.Eval("(define add (lambda (a b) (+ a b)))"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Eval("(define answer 42)"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		args    []interface{}
+		want    string
+		wantErr error
+	}{
+		{name: "add", args: []interface{}{1, 2.5}, want: "3.5"},
+		{name: "add", args: []interface{}{1}, wantErr: newError(ArityError, "expected two arguments to add, got 1")},
+		{name: "add", args: []interface{}{1, make(chan int)}, wantErr: errors.New("argument 2: cannot convert chan int to a lisp value")},
+		{name: "list", args: []interface{}{[]int{1, 2}, "x"}, want: `((1 2) "x")`},
+		{name: "answer", wantErr: errors.New("int is not a procedure")},
+		{name: "missing", wantErr: newError(NameError, "%q not found", "missing")},
+	}
+
+	for _, tt := range cases {
+		got, err := i.Call(tt.name, tt.args...)
+		if !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%s: got err %q, want err %q", tt.name, err, tt.wantErr)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCallback(t *testing.T) {
+	i := New()
+	handler, err := i.Eval("(lambda (x) (* x x))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := i.Apply(handler, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := ToGo(got, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 49 {
+		t.Errorf("got %d, want 49", n)
+	}
+}
@@ -1,109 +1,67 @@
 package golisp
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"log"
-	"os"
-	"strconv"
-	"strings"
 )
 
-func Repl() error {
-	for {
-		fmt.Print("golisp> ")
-		scanner := bufio.NewScanner(os.Stdin)
-		comment := 0
-		for scanner.Scan() {
-			var res *object
-			var err error
-			in := scanner.Text()
-			if in == "" || in[0] == ';' {
-				goto prompt
-			}
-			if strings.HasPrefix(in, "#|") {
-				comment++
-				continue
-			}
-			if comment > 0 {
-				if strings.HasPrefix(in, "|#") {
-					comment--
-				}
+// std is the interpreter used by Repl and Exec.
+var std = New()
 
-				if comment == 0 {
-					goto prompt
-				}
-				continue
-			}
-
-			log.Printf("executing %q\n", in)
-			res, err = Exec(in)
-			if err != nil {
-				fmt.Printf("ERROR: %s\n", err)
-				goto prompt
-			}
-			if res != nil {
-				fmt.Printf("%s\n", res.String())
-			}
-		prompt:
-			fmt.Print("golisp> ")
-		}
-		return scanner.Err()
-	}
-}
-
-func Exec(program string) (*object, error) {
-	ast, err := buildAST(program)
-	if err != nil {
-		return nil, fmt.Errorf("%s while parsing %q\n", err, program)
-	}
-
-	log.Printf("ast: %+v\n", ast)
-	return eval(globalEnv, ast)
-}
-
-func removeEmpty(tokens []string) []string {
-	b := tokens[:0]
-	for _, t := range tokens {
-		if len(t) != 0 {
-			b = append(b, t)
-		}
-	}
-	return b
+// Repl runs a read-eval-print loop on stdin using the default interpreter.
+func Repl() error {
+	return std.Repl()
 }
 
-func tokenize(program string) []string {
-	return removeEmpty(strings.Split(strings.Replace(strings.Replace(program, "(", " ( ", -1), ")", " ) ", -1), " "))
+// Exec evaluates program using the default interpreter.
+func Exec(program string) (Value, error) {
+	return std.Eval(program)
 }
 
 func atom(token string) (*object, error) {
 	if token == "" {
 		return nil, errors.New("unexpected empty token")
 	}
-	valInt, err := strconv.ParseInt(token, 10, 64)
-	if err == nil {
-		return newObject(valInt), nil
+	if token[0] == '"' {
+		s, err := unquote(token)
+		if err != nil {
+			return nil, err
+		}
+		return newString(s), nil
 	}
-	valFloat, err := strconv.ParseFloat(token, 64)
-	if err == nil {
-		return newObject(valFloat), nil
+	switch token {
+	case "#t", "#true":
+		return newObject(true), nil
+	case "#f", "#false":
+		return newObject(false), nil
+	}
+	if n, err := parseNumber(token); n != nil || err != nil {
+		return n, err
 	}
 	return newObject(token), nil
 }
 
-func lex(tokens []string) ([]string, *object, error) {
+// prefixes are the reader shorthands for quoting the datum that follows.
+var prefixes = map[string]string{
+	"'":  "quote",
+	"`":  "quasiquote",
+	",":  "unquote",
+	",@": "unquote-splicing",
+}
+
+func lex(tokens []token) ([]token, *object, error) {
 	log.Printf("lex called with %#v\n", tokens)
 	if len(tokens) == 0 {
-		return nil, nil, errors.New("unexpected EOF")
+		return nil, nil, &Error{Kind: SyntaxError, Err: errEOF}
 	}
 
-	var token string
-	token, tokens = tokens[0], tokens[1:]
-	log.Printf("-- %s .. %#v\n", token, tokens)
-	if token == "(" {
+	var t token
+	t, tokens = tokens[0], tokens[1:]
+	log.Printf("-- %s .. %#v\n", t.s, tokens)
+	if t.s == "(" {
 		l := newObject([]*object{})
-		for len(tokens) != 0 && tokens[0] != ")" {
+		for len(tokens) != 0 && tokens[0].s != ")" {
 			var ls *object
 			var err error
 			tokens, ls, err = lex(tokens)
@@ -115,120 +73,259 @@ func lex(tokens []string) ([]string, *object, error) {
 			l.l = append(l.l, ls)
 		}
 		if len(tokens) == 0 {
-			return nil, nil, errors.New("unexpected EOF")
+			return nil, nil, syntaxError(t.span, fmt.Errorf("%w, unclosed '('", errEOF))
+		}
+		for i, e := range l.l {
+			if isDot(e) && (i == 0 || i != len(l.l)-2) {
+				return nil, nil, syntaxError(*e.span, errors.New("unexpected '.'"))
+			}
 		}
 		// Pop off the ")"
-		_, tokens = tokens[0], tokens[1:]
-		return tokens, l, nil
+		t.span.EndLine, t.span.EndCol = tokens[0].span.EndLine, tokens[0].span.EndCol
+		l.span = &t.span
+		return tokens[1:], l, nil
 	}
-	if token == ")" {
-		// TODO: add the line/column to the error.
-		return nil, nil, errors.New("unexpected ')'")
+	if t.s == ")" {
+		return nil, nil, syntaxError(t.span, errors.New("unexpected ')'"))
 	}
-	a, err := atom(token)
-	return tokens, a, err
+	if name, ok := prefixes[t.s]; ok {
+		if len(tokens) == 0 {
+			return nil, nil, syntaxError(t.span, fmt.Errorf("%w, expected datum after %s", errEOF, t.s))
+		}
+		rest, d, err := lex(tokens)
+		if err != nil {
+			return nil, nil, err
+		}
+		t.span.EndLine, t.span.EndCol = d.span.EndLine, d.span.EndCol
+		l := newObject([]*object{newObject(name), d})
+		l.span = &t.span
+		return rest, l, nil
+	}
+	a, err := atom(t.s)
+	if err != nil {
+		return nil, nil, syntaxError(t.span, err)
+	}
+	a.span = &t.span
+	return tokens, a, nil
 }
 
-// Parse a program into an AST.
-func buildAST(program string) (*object, error) {
-	tokens := tokenize(program)
+// readForms parses each of the top-level forms in a program read from file.
+func readForms(file, program string) ([]*object, error) {
+	tokens, err := tokenize(file, program)
+	if err != nil {
+		return nil, err
+	}
 	log.Printf("tokens: %#v\n", tokens)
 
-	tokens, ast, err := lex(tokens)
-	if len(tokens) != 0 {
-		return nil, errors.New("unexpected leftover tokens")
+	forms := []*object{}
+	for len(tokens) != 0 {
+		var form *object
+		tokens, form, err = lex(tokens)
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, form)
 	}
-	return ast, err
+	return forms, nil
 }
 
-func eval(e env, o ...*object) (*object, error) {
-	log.Printf("eval called with %+v\n", o)
-	x := o[0]
-	log.Printf("checking operand %+v\n", x)
-	switch {
-	case x.t == TYPE_SYMBOL:
-		log.Printf("SYMBOL %q\n", x.s)
-		v, err := e.get(x.s)
+// eval evaluates x in e. Expressions in tail position (the branches of a
+// conditional, the last form of a begin or let body and the body of a lambda)
+// are evaluated by looping rather than recursing so that tail calls run in
+// constant Go stack. Errors are located at the innermost form being evaluated.
+func eval(e *env, x *object) (res *object, err error) {
+	defer func() {
 		if err != nil {
-			return nil, err
+			err = errorAt(x, err)
 		}
-		return v, nil
-	case x.t != TYPE_LIST:
-		log.Printf("CONSTANT %v\n", x)
-		return x, nil
-	case x.l[0].t == TYPE_BUILTIN:
-		log.Printf("BUILTIN %q\n", x.l[0].s)
-		switch x.l[0].s {
-		case "quote":
-			return x.l[1], nil
-		case "if":
-			test, conseq, alt := x.l[1], x.l[2], x.l[3]
-			// TODO: type check on everything
-			res, err := eval(e, test)
+	}()
+
+	for {
+		log.Printf("eval called with %+v\n", x)
+		switch {
+		case x.t == TYPE_SYMBOL && isKeyword(x.s):
+			log.Printf("KEYWORD %q\n", x.s)
+			return x, nil
+		case x.t == TYPE_SYMBOL:
+			log.Printf("SYMBOL %q\n", x.s)
+			v, err := e.get(x.s)
 			if err != nil {
 				return nil, err
 			}
-			log.Printf("test result: %#v", res)
-			exp := conseq
-			if !res.isTruthy() {
-				exp = alt
-			}
-			return eval(e, exp)
-		case "define":
-			v, exp := x.l[1], x.l[2]
-			// TODO: type check on v
-			ev, err := eval(e, exp)
-			if err != nil {
+			return v, nil
+		case x.t != TYPE_LIST:
+			log.Printf("CONSTANT %v\n", x)
+			return x, nil
+		case len(x.l) == 0:
+			log.Printf("returning empty\n")
+			return emptyList(), nil
+		case x.l[0].t == TYPE_BUILTIN:
+			log.Printf("BUILTIN %q\n", x.l[0].s)
+			switch x.l[0].s {
+			case "quote":
+				if err := checkForm(x, 1, 1); err != nil {
+					return nil, err
+				}
+				return toData(x.l[1]), nil
+			case "if", "cond", "case", "when", "unless", "and", "or":
+				var res, tail *object
+				switch x.l[0].s {
+				case "if":
+					res, tail, err = evalIf(e, x)
+				case "cond":
+					res, tail, err = evalCond(e, x)
+				case "case":
+					res, tail, err = evalCase(e, x)
+				case "when", "unless":
+					res, tail, err = evalWhen(e, x)
+				default:
+					res, tail, err = evalAnd(e, x)
+				}
+				if err != nil || tail == nil {
+					return res, err
+				}
+				x = tail
+				continue
+			case "begin":
+				if len(x.l) == 1 {
+					return nil, nil
+				}
+				for _, exp := range x.l[1 : len(x.l)-1] {
+					if _, err := eval(e, exp); err != nil {
+						return nil, err
+					}
+				}
+				x = x.l[len(x.l)-1]
+				continue
+			case "define":
+				return nil, evalDefine(e, x)
+			case "define-syntax":
+				return nil, defineSyntax(e, x)
+			case "let-syntax", "letrec-syntax":
+				ex, err := expandLetSyntax(e, x)
+				if err != nil {
+					return nil, err
+				}
+				x = ex
+				continue
+			case "guard":
+				inner, tail, err := evalGuard(e, x)
+				if err != nil {
+					return nil, err
+				}
+				e, x = inner, tail
+				continue
+			case "defmacro":
+				return nil, defmacro(e, x)
+			case "quasiquote":
+				if err := checkForm(x, 1, 1); err != nil {
+					return nil, err
+				}
+				return quasiquote(e, x.l[1], 1)
+			case "unquote", "unquote-splicing":
+				return nil, fmt.Errorf("unexpected %s outside quasiquote", x.l[0].s)
+			case "syntax-rules":
+				return nil, errors.New("unexpected syntax-rules outside define-syntax or let-syntax")
+			case "set!":
+				if err := checkForm(x, 2, 2); err != nil {
+					return nil, err
+				}
+				v, exp := x.l[1], x.l[2]
+				if v.t != TYPE_SYMBOL {
+					return nil, fmt.Errorf("expected symbol as first argument to set!, got %s", v)
+				}
+				ev, err := eval(e, exp)
+				if err != nil {
+					return nil, err
+				}
+				e.set(v.s, ev)
 				return nil, err
+			case "let", "let*", "letrec", "letrec*":
+				var inner *env
+				var body *object
+				switch x.l[0].s {
+				case "let":
+					inner, body, err = evalLet(e, x)
+				case "let*":
+					inner, body, err = evalLetStar(e, x)
+				default:
+					inner, body, err = evalLetrec(e, x)
+				}
+				if err != nil {
+					return nil, err
+				}
+				e, x = inner, body
+				continue
+			case "lambda":
+				if err := checkForm(x, 2, -1); err != nil {
+					return nil, err
+				}
+				l, err := makeLambda(e, x.l[1], x.l[2:])
+				if err != nil {
+					return nil, err
+				}
+				return newObject(l), nil
+			default:
+				return nil, fmt.Errorf("unknown builtin: %q", x.l[0].s)
 			}
-			e.define(v.s, ev)
-			return nil, err
-		case "set!":
-			v, exp := x.l[1], x.l[2]
-			ev, err := eval(e, exp)
+		default:
+			log.Printf("LIST %+v\n", x.l)
+			proc, err := eval(e, x.l[0])
+			log.Printf("-- got proc %+v\n", proc)
 			if err != nil {
 				return nil, err
 			}
-			e.set(v.s, ev)
-			return nil, err
-		case "lambda":
-			params, body := x.l[1], x.l[2]
-			l, err := newLambda(params, body, &e)
-			if err != nil {
-				return nil, err
+			// Macros not expanded before evaluation are expanded here.
+			if proc != nil && proc.t == TYPE_MACRO {
+				ex, err := proc.macro.expand(x)
+				if err != nil {
+					return nil, err
+				}
+				x = ex
+				continue
 			}
-			return newObject(l), nil
-		default:
-			return nil, fmt.Errorf("unknown builtin: %q", x.s)
-		}
-	default:
-		log.Printf("LIST %+v\n", x.l)
-		if len(x.l) == 0 {
-			log.Printf("returning empty\n")
-			return nil, nil
-		}
-		proc, err := eval(e, x.l[0])
-		log.Printf("-- got proc %+v\n", proc)
-		if err != nil {
-			return nil, err
-		}
-		// Evaluate the arguments.
-		opargs := x.l[1:]
-		args := make([]*object, len(opargs))
-		for i := range opargs {
-			args[i], err = eval(e, opargs[i])
-			if err != nil {
-				return nil, err
+			// Evaluate the arguments.
+			opargs := x.l[1:]
+			args := make([]*object, len(opargs))
+			for i := range opargs {
+				args[i], err = eval(e, opargs[i])
+				if err != nil {
+					return nil, err
+				}
+			}
+			if proc == nil {
+				return nil, errors.New("expected lambda or fn")
+			}
+			switch proc.t {
+			case TYPE_FN:
+				return proc.fn(args...)
+			case TYPE_LAMBDA:
+				// Rather than recursing through call, bind the arguments
+				// and loop on the body.
+				e, err = proc.lambda.bind(args...)
+				if err != nil {
+					return nil, err
+				}
+				x = proc.lambda.body
+				continue
+			default:
+				return nil, errors.New("expected lambda or fn")
 			}
-		}
-		switch proc.t {
-		case TYPE_FN:
-			return proc.fn(args...)
-		case TYPE_LAMBDA:
-			return proc.lambda.call(args...)
-		default:
-			return nil, errors.New("expected lambda or fn")
 		}
 	}
 }
 
+// apply calls the procedure proc with args.
+func apply(proc *object, args ...*object) (*object, error) {
+	if proc == nil {
+		return nil, errors.New("expected lambda or fn")
+	}
+	switch proc.t {
+	case TYPE_FN:
+		return proc.fn(args...)
+	case TYPE_LAMBDA:
+		return proc.lambda.call(args...)
+	default:
+		return nil, errors.New("expected lambda or fn")
+	}
+}
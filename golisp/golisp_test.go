@@ -2,54 +2,15 @@ package golisp
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestRemoveEmpty(t *testing.T) {
-	cases := []struct {
-		tokens, want []string
-	}{
-		{},
-		{[]string{}, []string{}},
-		{[]string{"", "foo", "", "bar", ""}, []string{"foo", "bar"}},
-		{[]string{"foo", "bar"}, []string{"foo", "bar"}},
-	}
-
-	for _, tt := range cases {
-		got := removeEmpty(tt.tokens)
-		if !reflect.DeepEqual(got, tt.want) {
-			t.Errorf("got %#v, want %#v", got, tt.want)
-		}
-	}
-}
-
-func TestTokenize(t *testing.T) {
-	cases := []struct {
-		program string
-		want    []string
-	}{
-		{
-			want: []string{},
-		},
-		{
-			program: "",
-			want:    []string{},
-		},
-		{
-			program: "(begin (* pi (* r r)))",
-			want:    []string{"(", "begin", "(", "*", "pi", "(", "*", "r", "r", ")", ")", ")"},
-		},
-	}
-
-	for _, tt := range cases {
-		got := tokenize(tt.program)
-		if !reflect.DeepEqual(got, tt.want) {
-			t.Errorf("got %#v, want %#v", got, tt.want)
-		}
-	}
-}
-
 func TestAtom(t *testing.T) {
 	cases := []struct {
 		token   string
@@ -60,6 +21,16 @@ func TestAtom(t *testing.T) {
 		{token: "42", want: &object{t: TYPE_INT, i: 42}},
 		{token: "42.3", want: &object{t: TYPE_FLOAT, f: 42.3}},
 		{token: "answer", want: &object{t: TYPE_SYMBOL, s: "answer"}},
+		{token: "#t", want: &object{t: TYPE_BOOL, b: true}},
+		{token: "#true", want: &object{t: TYPE_BOOL, b: true}},
+		{token: "#f", want: &object{t: TYPE_BOOL, b: false}},
+		{token: "#false", want: &object{t: TYPE_BOOL, b: false}},
+		{token: `"a b"`, want: &object{t: TYPE_STRING, s: "a b"}},
+		{token: `"\t\"\\\n\u00e9\U0001F600"`, want: &object{t: TYPE_STRING, s: "\t\"\\\n\u00e9\U0001F600"}},
+		{token: `"abc`, wantErr: errors.New("unterminated string")},
+		{token: `"abc\"`, wantErr: errors.New("unterminated string")},
+		{token: `"\q"`, wantErr: errors.New("invalid escape \\q")},
+		{token: `"\u12"`, wantErr: errors.New("invalid escape \\u12")},
 	}
 
 	for _, tt := range cases {
@@ -76,33 +47,63 @@ func TestAtom(t *testing.T) {
 func TestLex(t *testing.T) {
 	cases := []struct {
 		name    string
-		tokens  []string
+		program string
 		want    *object
 		wantErr error
 	}{
 		{
 			name:    "eof",
-			tokens:  []string{},
+			program: "",
 			wantErr: errors.New("unexpected EOF"),
 		},
 		{
-			name:   "int",
-			tokens: []string{"42"},
-			want:   newObject(42),
+			name:    "int",
+			program: "42",
+			want:    newObject(42),
 		},
 		{
 			name:    "unexpected ')'",
-			tokens:  []string{")"},
-			wantErr: errors.New("unexpected ')'"),
+			program: "\n  )",
+			wantErr: errors.New("2:3: unexpected ')'"),
 		},
 		{
 			name:    "unexpected EOF 2",
-			tokens:  []string{"(", "begin", "(", "define", "r", "10", ")", "(", "*", "pi", "(", "*", "r", "r", ")", ")"},
-			wantErr: errors.New("unexpected EOF"),
+			program: "(begin (define r 10) (* pi (* r r))",
+			wantErr: errors.New("1:1: unexpected EOF, unclosed '('"),
+		},
+		{
+			name:    "bad string",
+			program: `(list "\q")`,
+			wantErr: errors.New("1:7: invalid escape \\q"),
 		},
 		{
-			name:   "full",
-			tokens: []string{"(", "begin", "(", "define", "r", "10", ")", "r", ")"},
+			name:    "quote",
+			program: "'(a ,b ,@c)",
+			want: newObject([]*object{
+				newObject("quote"),
+				newObject([]*object{
+					newObject("a"),
+					newObject([]*object{newObject("unquote"), newObject("b")}),
+					newObject([]*object{newObject("unquote-splicing"), newObject("c")}),
+				}),
+			}),
+		},
+		{
+			name:    "quasiquote",
+			program: "``a",
+			want: newObject([]*object{
+				newObject("quasiquote"),
+				newObject([]*object{newObject("quasiquote"), newObject("a")}),
+			}),
+		},
+		{
+			name:    "dangling quote",
+			program: "'",
+			wantErr: errors.New("1:1: unexpected EOF, expected datum after '"),
+		},
+		{
+			name:    "full",
+			program: "(begin (define r 10) r)",
 			want: newObject([]*object{
 				newObject("begin"),
 				newObject([]*object{
@@ -116,16 +117,491 @@ func TestLex(t *testing.T) {
 	}
 
 	for _, tt := range cases {
-		tokens, got, err := lex(tt.tokens)
+		tokens, err := tokenize("", tt.program)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		tokens, got, err := lex(tokens)
 		if len(tokens) != 0 {
 			t.Fatalf("%s: unexpected extra tokens", tt.name)
 		}
 
-		if !reflect.DeepEqual(err, tt.wantErr) {
+		if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
 			t.Errorf("%s: got err %q, want err %q", tt.name, err, tt.wantErr)
 		}
-		if !reflect.DeepEqual(got, tt.want) {
+		if !equal(got, tt.want) {
 			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
 		}
 	}
 }
+
+func TestReadFormsSpans(t *testing.T) {
+	forms, err := readForms("f.lisp", "(define s\n\t\"héllo\") ; done\n42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forms) != 2 {
+		t.Fatalf("got %d forms, want 2", len(forms))
+	}
+	ast := forms[0]
+
+	cases := []struct {
+		o    *object
+		want Span
+	}{
+		{o: ast, want: Span{File: "f.lisp", Line: 1, Col: 1, EndLine: 2, EndCol: 10}},
+		{o: ast.l[0], want: Span{File: "f.lisp", Line: 1, Col: 2, EndLine: 1, EndCol: 8}},
+		{o: ast.l[1], want: Span{File: "f.lisp", Line: 1, Col: 9, EndLine: 1, EndCol: 10}},
+		{o: ast.l[2], want: Span{File: "f.lisp", Line: 2, Col: 2, EndLine: 2, EndCol: 9}},
+		{o: forms[1], want: Span{File: "f.lisp", Line: 3, Col: 1, EndLine: 3, EndCol: 3}},
+	}
+	for _, tt := range cases {
+		if tt.o.span == nil || tt.o.span.src == nil {
+			t.Fatalf("%s: missing span", tt.o)
+		}
+		got := *tt.o.span
+		got.src = nil
+		if got != tt.want {
+			t.Errorf("%s: got span %+v, want %+v", tt.o, tt.o.span, tt.want)
+		}
+	}
+
+	if _, err := readForms("f.lisp", "1 2)"); fmt.Sprint(err) != "f.lisp:1:4: unexpected ')'" {
+		t.Errorf("got err %q", err)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// Logging every eval makes a million iterations very slow.
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cases := []struct {
+		name     string
+		programs []string
+		want     *object
+	}{
+		{
+			name: "self",
+			programs: []string{
+				"(define loop (lambda (n acc) (if (= n 0) acc (loop (- n 1) (+ acc 1)))))",
+				"(loop 1000000 0)",
+			},
+			want: newObject(1000000),
+		},
+		{
+			name: "mutual",
+			programs: []string{
+				"(define even? (lambda (n) (if (= n 0) 1 (odd? (- n 1)))))",
+				"(define odd? (lambda (n) (if (= n 0) 0 (even? (- n 1)))))",
+				"(even? 1000001)",
+			},
+			want: newObject(0),
+		},
+		{
+			name: "begin",
+			programs: []string{
+				"(define loop (lambda (n) (begin n (if (= n 0) n (loop (- n 1))))))",
+				"(loop 1000000)",
+			},
+			want: newObject(0),
+		},
+		{
+			name: "cond",
+			programs: []string{
+				"(define loop (lambda (n) (cond ((= n 0) n) (else (loop (- n 1))))))",
+				"(loop 1000000)",
+			},
+			want: newObject(0),
+		},
+		{
+			name: "named let",
+			programs: []string{
+				"(let loop ((n 1000000)) (if (= n 0) n (loop (- n 1))))",
+			},
+			want: newObject(0),
+		},
+	}
+
+	for _, tt := range cases {
+		i := New()
+		var got Value
+		for _, p := range tt.programs {
+			var err error
+			got, err = i.Eval(p)
+			if err != nil {
+				t.Fatalf("%s: %s", tt.name, err)
+			}
+		}
+		if !equal(got.o, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got.o, tt.want)
+		}
+	}
+}
+
+func TestLet(t *testing.T) {
+	cases := []evalTest{
+		{
+			name:    "let",
+			program: "(let ((a 1) (b 2)) (+ a b))",
+			want:    "3",
+		},
+		{
+			name:    "shadowing",
+			program: "(define a 1) (let ((a 2) (b a)) (list a b))",
+			want:    "(2 1)",
+		},
+		{
+			name:    "no leak",
+			program: "(define a 1) (let ((a 2)) a) a",
+			want:    "1",
+		},
+		{
+			name:    "body",
+			program: "(let ((a 1)) (set! a (+ a 1)) (* a 10))",
+			want:    "20",
+		},
+		{
+			name:    "let*",
+			program: "(let* ((a 1) (b (+ a 1)) (a (* b 10))) (list a b))",
+			want:    "(20 2)",
+		},
+		{
+			name:    "letrec",
+			program: "(letrec ((even? (lambda (n) (if (= n 0) 1 (odd? (- n 1))))) (odd? (lambda (n) (if (= n 0) 0 (even? (- n 1)))))) (even? 10))",
+			want:    "1",
+		},
+		{
+			name:    "letrec*",
+			program: "(letrec* ((a 1) (b (+ a 1))) b)",
+			want:    "2",
+		},
+		{
+			name:    "named let",
+			program: "(let fact ((n 5) (acc 1)) (if (= n 0) acc (fact (- n 1) (* acc n))))",
+			want:    "120",
+		},
+		{
+			name:    "named let scope",
+			program: "(define loop 1) (let loop ((n 0)) n) loop",
+			want:    "1",
+		},
+		{
+			name:    "bad binding",
+			program: "(let ((a)) a)",
+			wantErr: "expected (name value) binding in let, got (a)",
+		},
+		{
+			name:    "symbol binding",
+			program: "(let (x) x)",
+			wantErr: "expected (name value) binding in let, got x",
+		},
+		{
+			name:    "no body",
+			program: "(let ((a 1)))",
+			wantErr: "expected bindings and body in let",
+		},
+	}
+
+	runEvalTests(t, cases)
+}
+
+func TestConditionals(t *testing.T) {
+	cases := []evalTest{
+		{
+			name:    "if",
+			program: "(if 0 1 2)",
+			want:    "2",
+		},
+		{
+			name:    "one-armed if",
+			program: "(if 0 1)",
+			wantNil: true,
+		},
+		{
+			name:    "if arity",
+			program: "(if 1)",
+			wantErr: "expected at least two arguments to if",
+		},
+		{
+			name:    "if too many",
+			program: "(if 1 2 3 4)",
+			wantErr: "expected at most three arguments to if",
+		},
+		{
+			name:    "cond",
+			program: "(cond ((= 1 2) 1) ((= 1 1) 2 3) (else 4))",
+			want:    "3",
+		},
+		{
+			name:    "cond else",
+			program: "(cond (0 1) (else 2))",
+			want:    "2",
+		},
+		{
+			name:    "cond test",
+			program: "(cond (0) (5))",
+			want:    "5",
+		},
+		{
+			name:    "cond arrow",
+			program: "(cond ((car (list 5)) => (lambda (x) (* x 2))))",
+			want:    "10",
+		},
+		{
+			name:    "cond no match",
+			program: "(cond (0 1))",
+			wantNil: true,
+		},
+		{
+			name:    "cond else not last",
+			program: "(cond (else 1) (1 2))",
+			wantErr: "expected else clause to be last in cond",
+		},
+		{
+			name:    "cond bad clause",
+			program: "(cond 1)",
+			wantErr: "expected (test body ...) clause in cond, got 1",
+		},
+		{
+			name:    "case",
+			program: "(case (* 2 3) ((2 3 5 7) 1) ((1 4 6 8 9) 2) (else 3))",
+			want:    "2",
+		},
+		{
+			name:    "case symbol",
+			program: "(case (quote b) ((a) 1) ((b c) 2))",
+			want:    "2",
+		},
+		{
+			name:    "case else",
+			program: "(case 10 ((1) 1) (else => (lambda (x) (+ x 1))))",
+			want:    "11",
+		},
+		{
+			name:    "when",
+			program: "(when 1 2 3)",
+			want:    "3",
+		},
+		{
+			name:    "when false",
+			program: "(when 0 (undefined))",
+			wantNil: true,
+		},
+		{
+			name:    "unless",
+			program: "(unless 0 2 3)",
+			want:    "3",
+		},
+		{
+			name:    "and",
+			program: "(and 1 2 3)",
+			want:    "3",
+		},
+		{
+			name:    "and short-circuit",
+			program: "(and 1 0 (undefined))",
+			want:    "0",
+		},
+		{
+			name:    "and empty",
+			program: "(and)",
+			want:    "#t",
+		},
+		{
+			name:    "or",
+			program: "(or 0 2 (undefined))",
+			want:    "2",
+		},
+		{
+			name:    "or empty",
+			program: "(or)",
+			want:    "#f",
+		},
+		{
+			name:    "quote arity",
+			program: "(quote)",
+			wantErr: "expected one argument to quote",
+		},
+	}
+
+	runEvalTests(t, cases)
+}
+
+func TestBodies(t *testing.T) {
+	cases := []evalTest{
+		{
+			name:    "begin",
+			program: "(begin 1 2 3)",
+			want:    "3",
+		},
+		{
+			name:    "begin empty",
+			program: "(begin)",
+			wantNil: true,
+		},
+		{
+			name:    "begin define",
+			program: "(begin (define a 1) (define b 2)) (+ a b)",
+			want:    "3",
+		},
+		{
+			name:    "lambda body",
+			program: "(define f (lambda (x) (set! x (* x 2)) (+ x 1))) (f 5)",
+			want:    "11",
+		},
+		{
+			name: "internal defines",
+			program: `(define f (lambda (n)
+				(define even? (lambda (n) (if (= n 0) 1 (odd? (- n 1)))))
+				(define odd? (lambda (n) (if (= n 0) 0 (even? (- n 1)))))
+				(even? n)))
+				(list (f 10) (f 7))`,
+			want: "(1 0)",
+		},
+		{
+			name:    "internal define scope",
+			program: "(define a 1) (define f (lambda () (define a 2) a)) (list (f) a)",
+			want:    "(2 1)",
+		},
+		{
+			name:    "let internal define",
+			program: "(let ((a 1)) (define b (+ a 1)) (* b 10))",
+			want:    "20",
+		},
+		{
+			name:    "lambda no body",
+			program: "(lambda (x))",
+			wantErr: "expected at least two arguments to lambda",
+		},
+	}
+
+	runEvalTests(t, cases)
+}
+
+func TestDefineForm(t *testing.T) {
+	cases := []evalTest{
+		{
+			name:    "value",
+			program: "(define a (+ 1 2)) a",
+			want:    "3",
+		},
+		{
+			name:    "procedure",
+			program: "(define (f a b) (define c (* a b)) (+ c 1)) (f 2 3)",
+			want:    "7",
+		},
+		{
+			name:    "rest",
+			program: "(define (f a . rest) rest) (f 1 2 3)",
+			want:    "(2 3)",
+		},
+		{
+			name:    "curried",
+			program: "(define ((adder n) x) (+ n x)) ((adder 2) 3)",
+			want:    "5",
+		},
+		{
+			name:    "docstring",
+			program: "(define (f) \"Returns one.\" 1) (f)",
+			want:    "1",
+		},
+		{
+			name:    "string body",
+			program: "(define (f) \"hi\") (f)",
+			want:    `"hi"`,
+		},
+		{
+			name:    "not a symbol",
+			program: "(define 1 2)",
+			wantErr: "expected symbol to define, got 1",
+		},
+		{
+			name:    "no name",
+			program: "(define () 2)",
+			wantErr: "expected name in define",
+		},
+		{
+			name:    "too many",
+			program: "(define a 1 2)",
+			wantErr: "expected two arguments to define",
+		},
+		{
+			name:    "set! not a symbol",
+			program: "(set! (a) 2)",
+			wantErr: "expected symbol as first argument to set!, got (a)",
+		},
+	}
+
+	runEvalTests(t, cases)
+}
+
+func TestQuasiquote(t *testing.T) {
+	cases := []evalTest{
+		{program: "'(a b)", want: "(a b)"},
+		{program: "`(a b)", want: "(a b)"},
+		{program: "(define b 2) `(a ,b ,(+ b 1))", want: "(a 2 3)"},
+		{program: "(define l '(1 2)) `(a ,@l b ,@l)", want: "(a 1 2 b 1 2)"},
+		{program: "`(a ,@(list) b)", want: "(a b)"},
+		{program: "(define x 1) `(a (b ,x) ((c ,x)))", want: "(a (b 1) ((c 1)))"},
+		{program: "(define x 1) `(a `(b ,(c ,x)))", want: "(a (quasiquote (b (unquote (c 1)))))"},
+		{program: "(define x 1) `(a `(b ,,x))", want: "(a (quasiquote (b (unquote 1))))"},
+		{program: "`,(+ 1 2)", want: "3"},
+		{program: "`(a ,@1)", wantErr: "expected list to unquote-splicing, got 1"},
+		{program: ",a", wantErr: "unexpected unquote outside quasiquote"},
+	}
+	runEvalTests(t, cases)
+}
+
+func TestBooleans(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{program: "(> 2 1)", want: "#t"},
+		{program: "(list #t #f #true #false)", want: "(#t #f #t #f)"},
+		{program: "(list (equal? 1 1) (number? 'a) (boolean? #f) (boolean? 0))", want: "(#t #f #t #f)"},
+		{program: "(+ (equal? 1 1) 1)", wantErr: `cannot convert "bool" to float`},
+		{program: "(if #f 1 2)", want: "2"},
+		{program: "(list (if 0 'true 'false) (if '() 'true 'false))", want: "(false false)"},
+	})
+	runEvalTests(t, []evalTest{
+		{program: "(list (if 0 'true 'false) (if '() 'true 'false))", want: "(true true)"},
+		{program: "(list (if #f 'true 'false) (and 1 #f 2) (or #f 0))", want: "(false #f 0)"},
+		{program: "(cond (0 'zero) (else 'else))", want: "zero"},
+	}, WithSchemeTruthiness())
+}
+
+// evalTest is a program to evaluate in a new interpreter, with the printed
+// value it should give, whether it should give no value, or a string that the
+// error it should fail with contains.
+type evalTest struct {
+	name    string
+	program string
+	want    string
+	wantNil bool
+	wantErr string
+}
+
+// runEvalTests evaluates each of tests in a new interpreter made with opts.
+func runEvalTests(t *testing.T, tests []evalTest, opts ...Option) {
+	t.Helper()
+	for _, tt := range tests {
+		name := tt.name
+		if name == "" {
+			name = fmt.Sprintf("%q", tt.program)
+		}
+		got, err := New(opts...).Eval(tt.program)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want %q", name, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %s", name, err)
+		case tt.wantNil:
+			if !got.IsNil() {
+				t.Errorf("%s: got %s, want no value", name, got)
+			}
+		case got.IsNil() || got.String() != tt.want:
+			t.Errorf("%s: got %s, want %s", name, got, tt.want)
+		}
+	}
+}
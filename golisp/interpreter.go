@@ -0,0 +1,361 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+// Interpreter evaluates programs against its own global environment. Separate
+// interpreters share no state.
+type Interpreter struct {
+	env *env
+	in  io.Reader
+	out io.Writer
+	// historyFile is where the REPL keeps its history.
+	historyFile string
+	// args is returned by command-line.
+	args []string
+}
+
+// Option configures an Interpreter.
+type Option func(*Interpreter)
+
+// WithInput sets the reader the REPL reads from. The default is os.Stdin.
+func WithInput(r io.Reader) Option {
+	return func(i *Interpreter) {
+		i.in = r
+	}
+}
+
+// WithOutput sets the writer the REPL, help, display, write and newline write
+// to. The default is os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.out = w
+	}
+}
+
+// WithHistoryFile sets the file the REPL line editor keeps its history in. The
+// default is .golisp_history in the user's home directory and an empty path
+// disables saving history.
+func WithHistoryFile(path string) Option {
+	return func(i *Interpreter) {
+		i.historyFile = path
+	}
+}
+
+// WithArgs sets the list of strings returned by (command-line). By convention
+// the first is the name of the script being run.
+func WithArgs(args ...string) Option {
+	return func(i *Interpreter) {
+		i.args = args
+	}
+}
+
+// WithSchemeTruthiness makes #f the only false value, as in Scheme. By
+// default nil, 0, 0.0 and the empty list are also false.
+func WithSchemeTruthiness() Option {
+	return func(i *Interpreter) {
+		i.env.dyn.scheme = true
+	}
+}
+
+// New returns an interpreter with a fresh global environment.
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{
+		env:         newGlobalEnv(),
+		in:          os.Stdin,
+		out:         os.Stdout,
+		historyFile: defaultHistoryFile(),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	i.env.define("command-line", newObject(func(o ...*object) (*object, error) {
+		if len(o) != 0 {
+			return nil, errors.New("expected no arguments to command-line")
+		}
+		l := make([]*object, len(i.args))
+		for n, a := range i.args {
+			l[n] = newString(a)
+		}
+		return list(l...), nil
+	}))
+	i.env.define("display", newObject(func(o ...*object) (*object, error) {
+		if len(o) != 1 {
+			return nil, errors.New("expected one argument to display")
+		}
+		if o[0] != nil && o[0].t == TYPE_STRING {
+			fmt.Fprint(i.out, o[0].s)
+		} else {
+			fmt.Fprint(i.out, o[0])
+		}
+		return nil, nil
+	}))
+	i.env.define("write", newObject(func(o ...*object) (*object, error) {
+		if len(o) != 1 {
+			return nil, errors.New("expected one argument to write")
+		}
+		fmt.Fprint(i.out, o[0])
+		return nil, nil
+	}))
+	i.env.define("newline", newObject(func(o ...*object) (*object, error) {
+		if len(o) != 0 {
+			return nil, errors.New("expected no arguments to newline")
+		}
+		fmt.Fprintln(i.out)
+		return nil, nil
+	}))
+	help := newObject(func(o ...*object) (*object, error) {
+		if len(o) != 1 {
+			return nil, errors.New("expected one argument to help")
+		}
+		i.help(o[0])
+		return nil, nil
+	})
+	i.env.define("macroexpand", newObject(func(o ...*object) (*object, error) {
+		if len(o) != 1 {
+			return nil, errors.New("expected one argument to macroexpand")
+		}
+		x, err := toSyntax(o[0])
+		if err != nil {
+			return nil, err
+		}
+		x, err = expand(i.env, x)
+		if err != nil {
+			return nil, err
+		}
+		return toData(x), nil
+	}))
+	i.env.define("help", help)
+	i.env.define("doc", help)
+	return i
+}
+
+// help prints the params and docstring of the procedure o.
+func (i *Interpreter) help(o *object) {
+	switch {
+	case o == nil:
+		fmt.Fprintln(i.out, "no help for nil")
+	case o.t == TYPE_FN:
+		fmt.Fprintln(i.out, "built-in procedure")
+	case o.t == TYPE_LAMBDA:
+		fmt.Fprintln(i.out, o.lambda.signature())
+		if o.lambda.doc == "" {
+			return
+		}
+		for _, line := range strings.Split(o.lambda.doc, "\n") {
+			fmt.Fprintf(i.out, "  %s\n", strings.TrimSpace(line))
+		}
+	default:
+		fmt.Fprintf(i.out, "no help for %s %s\n", o.t, o)
+	}
+}
+
+// Eval evaluates each of the forms in program in order and returns the value
+// of the last.
+func (i *Interpreter) Eval(program string) (Value, error) {
+	return i.evalForms("", program)
+}
+
+// EvalReader evaluates each of the forms read from r in order and returns the
+// value of the last. A first line starting with #! is ignored.
+func (i *Interpreter) EvalReader(r io.Reader) (Value, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Value{}, err
+	}
+	return i.evalForms("", skipShebang(string(b)))
+}
+
+// EvalFile evaluates each of the forms in the file at path in order and returns
+// the value of the last. A first line starting with #! is ignored.
+func (i *Interpreter) EvalFile(path string) (Value, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Value{}, err
+	}
+	return i.evalForms(path, skipShebang(string(b)))
+}
+
+// skipShebang blanks out a first line starting with #! so that it is skipped
+// but lines are still numbered from the top of the file.
+func skipShebang(src string) string {
+	if !strings.HasPrefix(src, "#!") {
+		return src
+	}
+	if n := strings.IndexByte(src, '\n'); n != -1 {
+		return src[n:]
+	}
+	return ""
+}
+
+// evalForms evaluates each of the forms in src, read from file, in order and
+// returns the value of the last.
+func (i *Interpreter) evalForms(file, src string) (Value, error) {
+	forms, err := readForms(file, src)
+	if err != nil {
+		return Value{}, err
+	}
+	var res *object
+	for _, f := range forms {
+		log.Printf("form: %+v\n", f)
+		if f, err = expand(i.env, f); err != nil {
+			return Value{}, err
+		}
+		if res, err = eval(i.env, f); err != nil {
+			return Value{}, err
+		}
+	}
+	return Value{res}, nil
+}
+
+const (
+	prompt             = "golisp> "
+	continuationPrompt = "...> "
+)
+
+// Repl runs a read-eval-print loop until the input is exhausted. Input is
+// read until it forms a complete expression, so definitions may span lines.
+// An interrupt discards a partially entered expression. If the input and
+// output are a terminal, lines are read with a line editor that supports
+// cursor movement, history, reverse search with ctrl-r and tab completion.
+func (i *Interpreter) Repl() error {
+	if in, ok := i.in.(*os.File); ok && isTerminal(int(in.Fd())) {
+		if out, ok := i.out.(*os.File); ok && isTerminal(int(out.Fd())) {
+			return i.editRepl(in, out)
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	return i.repl(newScanReader(i.in, i.out, interrupt))
+}
+
+// editRepl runs the REPL with a line editor on the terminal in and out.
+func (i *Interpreter) editRepl(in, out *os.File) error {
+	ed := newEditor(in, out, terminalWidth(int(out.Fd())), i.completions)
+	if err := ed.loadHistory(i.historyFile); err != nil {
+		log.Printf("failed to load history: %s", err)
+	}
+	return i.repl(&rawReader{ed, int(in.Fd())})
+}
+
+// rawReader reads lines with an editor, with the terminal in raw mode only
+// while a line is being read.
+type rawReader struct {
+	*editor
+	fd int
+}
+
+func (r *rawReader) readLine(prompt string) (string, error) {
+	restore, err := rawMode(r.fd)
+	if err != nil {
+		return "", err
+	}
+	line, err := r.editor.readLine(prompt)
+	if rerr := restore(); err == nil {
+		err = rerr
+	}
+	if err == nil {
+		if herr := r.addHistory(line); herr != nil {
+			log.Printf("failed to save history: %s", herr)
+		}
+	}
+	return line, err
+}
+
+// completions returns the sorted names defined in the global environment and
+// special forms that start with prefix.
+func (i *Interpreter) completions(prefix string) []string {
+	seen := map[string]bool{}
+	for e := i.env; e != nil; e = e.outer {
+		for k := range e.m {
+			if strings.HasPrefix(k, prefix) {
+				seen[k] = true
+			}
+		}
+	}
+	for _, b := range builtins {
+		if strings.HasPrefix(b, prefix) {
+			seen[b] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// repl evaluates the expressions formed by the lines read from r until it is
+// exhausted.
+func (i *Interpreter) repl(r lineReader) error {
+	var pending string
+	for {
+		p := prompt
+		if pending != "" {
+			p = continuationPrompt
+		}
+		in, err := r.readLine(p)
+		if err == errInterrupted {
+			pending = ""
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		pending += in + "\n"
+
+		blank, complete := scanInput(pending)
+		if blank {
+			pending = ""
+			continue
+		}
+		if !complete {
+			continue
+		}
+
+		log.Printf("executing %q\n", pending)
+		res, err := i.Eval(pending)
+		pending = ""
+		if err != nil {
+			fmt.Fprintf(i.out, "ERROR: %s\n", RenderError(err))
+			continue
+		}
+		if !res.IsNil() {
+			fmt.Fprintf(i.out, "%s\n", res.String())
+		}
+	}
+}
+
+// scanInput reports whether src contains nothing but whitespace and comments,
+// and whether it is complete, that is, does not end inside a list, string or
+// comment.
+func scanInput(src string) (blank, complete bool) {
+	tokens, err := tokenize("", src)
+	if errors.Is(err, errEOF) {
+		return false, false
+	}
+	if err != nil {
+		return false, true
+	}
+	if len(tokens) == 0 {
+		return true, true
+	}
+	_, err = readForms("", src)
+	return false, !errors.Is(err, errEOF)
+}
@@ -0,0 +1,357 @@
+package golisp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpreterIsolation(t *testing.T) {
+	a, b := New(), New()
+	if _, err := a.Eval("(define x 42)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Eval("(define + -)"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := a.Eval("(+ x 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := newObject(43); !reflect.DeepEqual(got.o, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := b.Eval("x"); err == nil {
+		t.Errorf("expected x to be undefined in a separate interpreter")
+	}
+}
+
+func TestEvalReader(t *testing.T) {
+	got, err := New().EvalReader(strings.NewReader("(* 6 7)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := newObject(42); !reflect.DeepEqual(got.o, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader("(define r 10)\n; comment\n(* r r)\n(car 1)\n")
+	if err := New(WithInput(in), WithOutput(&out)).Repl(); err != nil {
+		t.Fatal(err)
+	}
+	want := "golisp> golisp> golisp> 100\ngolisp> ERROR: 1:1: expected pair as argument to car\n\t(car 1)\n\t^~~~~~~\ngolisp> "
+	if got := out.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReplMultiLine(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader(`(define square
+  (lambda (x)
+    ; comment with a )
+    (* x x)))
+(square
+ "#| not a comment
+)")
+#| a block
+   comment |#
+
+(square 4)
+`)
+	if err := New(WithInput(in), WithOutput(&out)).Repl(); err != nil {
+		t.Fatal(err)
+	}
+	want := "golisp> ...> ...> ...> " +
+		"golisp> ...> ...> ERROR: 4:5: cannot convert \"string\" to float\n\t    (* x x)))\n\t    ^~~~~~~\n" +
+		"golisp> ...> golisp> golisp> 16\ngolisp> "
+	if got := out.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// fakeReader is a lineReader that returns lines in order, followed by io.EOF.
+// A nil line is returned as an interrupt.
+type fakeReader struct {
+	out   *bytes.Buffer
+	lines []*string
+}
+
+func (r *fakeReader) readLine(prompt string) (string, error) {
+	r.out.WriteString(prompt)
+	if len(r.lines) == 0 {
+		return "", io.EOF
+	}
+	l := r.lines[0]
+	r.lines = r.lines[1:]
+	if l == nil {
+		r.out.WriteString("^C\n")
+		return "", errInterrupted
+	}
+	return *l, nil
+}
+
+func TestReplInterrupt(t *testing.T) {
+	var out bytes.Buffer
+	line := func(s string) *string { return &s }
+	r := &fakeReader{&out, []*string{line("(define x"), line("  (car"), nil, line("(+ 1 2)")}}
+	if err := New(WithOutput(&out)).repl(r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "golisp> ...> ...> ^C\ngolisp> 3\ngolisp> "
+	if got := out.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestScanReaderInterrupt(t *testing.T) {
+	var out bytes.Buffer
+	interrupt := make(chan os.Signal, 1)
+	interrupt <- os.Interrupt
+	r := newScanReader(strings.NewReader(""), &out, interrupt)
+	if _, err := r.readLine("> "); err != errInterrupted {
+		t.Errorf("got err %v, want %v", err, errInterrupted)
+	}
+	if _, err := r.readLine("> "); err != io.EOF {
+		t.Errorf("got err %v, want %v", err, io.EOF)
+	}
+	if want := "> \n> "; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	i := New()
+	if _, err := i.Eval("(define string-reverse 1)"); err != nil {
+		t.Fatal(err)
+	}
+	got := i.completions("string-")
+	want := []string{"string->number", "string->symbol", "string-append", "string-downcase", "string-join", "string-length", "string-reverse", "string-split", "string-upcase"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := i.completions("se"), []string{"set!", "set-car!", "set-cdr!"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestScanInput(t *testing.T) {
+	cases := []struct {
+		src             string
+		blank, complete bool
+	}{
+		{src: "", blank: true, complete: true},
+		{src: "  ; comment\n", blank: true, complete: true},
+		{src: "#| open", complete: false},
+		{src: "(+ 1", complete: false},
+		{src: "(+ 1 \"a)", complete: false},
+		{src: "(+ 1 2)", complete: true},
+		{src: "(+ 1 2) (+ 3", complete: false},
+		{src: "(+ 1 2) (+ 3 4)", complete: true},
+		{src: "x", complete: true},
+		{src: ")", complete: true},
+	}
+	for _, tt := range cases {
+		blank, complete := scanInput(tt.src)
+		if blank != tt.blank || complete != tt.complete {
+			t.Errorf("%q: got %t, %t, want %t, %t", tt.src, blank, complete, tt.blank, tt.complete)
+		}
+	}
+}
+
+func TestEvalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golisp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name, src string
+		want      string
+		wantErr   string
+	}{
+		{
+			name: "forms.lisp",
+			src:  "(define sq (lambda (x) (* x x)))\n; comment\n(sq (string->number (car (cdr (command-line)))))\n",
+			want: "49",
+		},
+		{
+			name: "shebang.lisp",
+			src:  "#!/usr/bin/env golisp\n(define x 1)\n(+ x 2)",
+			want: "3",
+		},
+		{
+			name: "shebang-only.lisp",
+			src:  "#!/usr/bin/env golisp",
+			want: "",
+		},
+		{
+			name:    "error.lisp",
+			src:     "#!/usr/bin/env golisp\n(define x 1)\n(+ x y)\n",
+			wantErr: "error.lisp:3:6: \"y\" not found",
+		},
+	}
+
+	for _, tt := range cases {
+		path := filepath.Join(dir, tt.name)
+		if err := ioutil.WriteFile(path, []byte(tt.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := New(WithArgs(path, "7")).EvalFile(path)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != filepath.Join(dir, tt.wantErr) {
+				t.Errorf("%s: got err %v, want err %s", tt.name, err, filepath.Join(dir, tt.wantErr))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := New().EvalFile(filepath.Join(dir, "missing.lisp")); !os.IsNotExist(err) {
+		t.Errorf("got err %v, want not exist", err)
+	}
+}
+
+func TestEvalReaderForms(t *testing.T) {
+	got, err := New().EvalReader(strings.NewReader("(define x 6)\n(define y 7)\n(* x y)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "42" {
+		t.Errorf("got %q, want 42", got)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	got, err := New(WithArgs("script.lisp", "a b")).Eval("(command-line)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `("script.lisp" "a b")`; got.String() != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, _ := New().Eval("(command-line)"); got.String() != "()" {
+		t.Errorf("got %q, want ()", got)
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	cases := []struct {
+		program string
+		want    string
+	}{
+		{program: `(display "a\tb") (newline)`, want: "a\tb\n"},
+		{program: `(write "a\tb")`, want: `"a\tb"`},
+		{program: "(display (list 1 \"b\" 'c))", want: `(1 "b" c)`},
+		{program: "(display (+ 1 2)) (newline) (display 'x)", want: "3\nx"},
+	}
+	for _, tt := range cases {
+		var out bytes.Buffer
+		got, err := New(WithOutput(&out)).EvalReader(strings.NewReader(tt.program))
+		if err != nil {
+			t.Fatalf("%q: %s", tt.program, err)
+		}
+		if !got.IsNil() {
+			t.Errorf("%q: got %s, want no value", tt.program, got)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.program, out.String(), tt.want)
+		}
+	}
+
+	for _, program := range []string{"(display)", "(write 1 2)", "(newline 1)"} {
+		if _, err := New().Eval(program); err == nil {
+			t.Errorf("%q: expected error", program)
+		}
+	}
+}
+
+func TestEvalForms(t *testing.T) {
+	cases := []struct {
+		program string
+		want    string
+	}{
+		{program: "", want: ""},
+		{program: "; nothing", want: ""},
+		{program: "(define x 1) (+ x 2)", want: "3"},
+		{program: "(define x 1)\n(set! x (+ x 1))\n(* x 10)", want: "20"},
+		{program: "1 2 3", want: "3"},
+		{program: "(+ 1 2) (define y 3)", want: ""},
+	}
+	for _, tt := range cases {
+		got, err := New().Eval(tt.program)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.program, err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.program, got, tt.want)
+		}
+	}
+
+	// Forms before an error are still evaluated.
+	i := New()
+	if _, err := i.Eval("(define x 1) (car x) (define x 2)"); err == nil {
+		t.Fatal("expected error")
+	}
+	if got, _ := i.Eval("x"); got.String() != "1" {
+		t.Errorf("got %q, want 1", got)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	cases := []struct {
+		program string
+		want    string
+	}{
+		{
+			program: "(define (f a b) \"Adds a and b.\" (+ a b)) (help f)",
+			want:    "(f a b)\n  Adds a and b.\n",
+		},
+		{
+			program: "(define (f . args) \"Line one.\n  Line two.\" args) (doc f)",
+			want:    "(f . args)\n  Line one.\n  Line two.\n",
+		},
+		{
+			program: "(define (f) 1) (help f)",
+			want:    "(f)\n",
+		},
+		{
+			program: "(help (lambda (x &optional y) x))",
+			want:    "(lambda x &optional y)\n",
+		},
+		{
+			program: "(help car)",
+			want:    "built-in procedure\n",
+		},
+		{
+			program: "(help 42)",
+			want:    "no help for int 42\n",
+		},
+	}
+	for _, tt := range cases {
+		var out bytes.Buffer
+		if _, err := New(WithOutput(&out)).Eval(tt.program); err != nil {
+			t.Fatalf("%q: %s", tt.program, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.program, out.String(), tt.want)
+		}
+	}
+}
@@ -5,40 +5,233 @@ import (
 	"fmt"
 )
 
+// param is an optional or keyword parameter and the expression giving its
+// value when no argument is passed for it.
+type param struct {
+	name string
+	def  *object
+}
+
 type lambda struct {
+	// name is the name the lambda was defined with, if any, for errors.
+	name string
+	// doc is the docstring given as the first form of the body, if any.
+	doc    string
 	params *object
 	body   *object
 	outer  *env
+
+	required []string
+	optional []param
+	keys     []param
+	rest     string
 }
 
+// newLambda returns a lambda taking params. params is either a symbol, bound
+// to a list of all of the arguments, or a list of required parameters
+// optionally followed by
+//
+//	&optional a (b default) ...
+//	&key a (b default) ...
+//	. rest    or    &rest rest
+//
+// Keyword arguments are passed as :name value.
 func newLambda(params, body *object, env *env) (*lambda, error) {
 	if params == nil || body == nil {
 		return nil, errors.New("nil params or body")
 	}
+	l := &lambda{params: params, body: body, outer: env}
+	if params.t == TYPE_SYMBOL {
+		l.rest = params.s
+		return l, nil
+	}
 	if params.t != TYPE_LIST {
 		return nil, errors.New("invalid params. expected list.")
 	}
-	for _, p := range params.l {
-		if p.t != TYPE_SYMBOL {
+
+	mode := ""
+	for i := 0; i < len(params.l); i++ {
+		p := params.l[i]
+		if p.t == TYPE_SYMBOL {
+			switch p.s {
+			case ".", "&rest":
+				if i != len(params.l)-2 || params.l[i+1].t != TYPE_SYMBOL {
+					return nil, fmt.Errorf("expected one symbol after %s in params", p.s)
+				}
+				l.rest = params.l[i+1].s
+				return l, nil
+			case "&optional":
+				if mode != "" {
+					return nil, fmt.Errorf("unexpected %s after %s in params", p.s, mode)
+				}
+				mode = p.s
+				continue
+			case "&key":
+				if mode == p.s {
+					return nil, fmt.Errorf("unexpected %s after %s in params", p.s, mode)
+				}
+				mode = p.s
+				continue
+			}
+		}
+
+		var opt param
+		switch {
+		case p.t == TYPE_SYMBOL:
+			opt.name = p.s
+		case mode != "" && p.t == TYPE_LIST && len(p.l) == 2 && p.l[0].t == TYPE_SYMBOL:
+			opt = param{p.l[0].s, p.l[1]}
+		default:
 			return nil, fmt.Errorf("unexpected non-symbolic param: %s", p)
 		}
+		switch mode {
+		case "&optional":
+			l.optional = append(l.optional, opt)
+		case "&key":
+			l.keys = append(l.keys, opt)
+		default:
+			l.required = append(l.required, opt.name)
+		}
 	}
-	return &lambda{params, body, env}, nil
+	return l, nil
 }
 
-func (l *lambda) call(args ...*object) (*object, error) {
-	if len(args) != len(l.params.l) {
-		return nil, fmt.Errorf("mismatch number of args %d to params %d.", len(args), len(l.params.l))
+// signature returns a call to the lambda with its params as arguments, as in
+// (name a b . rest).
+func (l *lambda) signature() string {
+	name := l.name
+	if name == "" {
+		name = "lambda"
+	}
+	if l.params.t == TYPE_SYMBOL {
+		return fmt.Sprintf("(%s . %s)", name, l.params.s)
+	}
+	if len(l.params.l) == 0 {
+		return fmt.Sprintf("(%s)", name)
+	}
+	p := l.params.String()
+	return fmt.Sprintf("(%s %s", name, p[1:])
+}
+
+// arity describes the number of arguments the lambda takes.
+func (l *lambda) arity() string {
+	n := len(l.required)
+	switch {
+	case l.rest != "" || len(l.keys) != 0:
+		return "at least " + numArgs(n)
+	case len(l.optional) != 0:
+		return fmt.Sprintf("between %d and %d arguments", n, n+len(l.optional))
+	}
+	return numArgs(n)
+}
+
+// arityError returns an error for a call to the lambda with n arguments.
+func (l *lambda) arityError(n int) error {
+	name := l.name
+	if name == "" {
+		name = "lambda"
+	}
+	return newError(ArityError, "expected %s to %s, got %d", l.arity(), name, n)
+}
+
+// bind returns a new scope, enclosed by the lambda's, with the params bound to
+// args. Defaults are evaluated in the new scope, so may refer to the params
+// before them.
+func (l *lambda) bind(args ...*object) (*env, error) {
+	if len(args) < len(l.required) {
+		return nil, l.arityError(len(args))
 	}
 
-	e := env{
+	e := &env{
 		outer: l.outer,
 		m:     map[string]*object{},
 	}
 
-	for i, _ := range l.params.l {
-		e.define(l.params.l[i].s, args[i])
+	for i, p := range l.required {
+		e.define(p, args[i])
+	}
+	rest := args[len(l.required):]
+
+	for _, p := range l.optional {
+		if len(rest) > 0 {
+			e.define(p.name, rest[0])
+			rest = rest[1:]
+			continue
+		}
+		if err := l.bindDefault(e, p); err != nil {
+			return nil, err
+		}
 	}
 
+	if len(l.keys) != 0 {
+		if err := l.bindKeys(e, rest); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case l.rest != "":
+		e.define(l.rest, list(rest...))
+	case len(rest) > 0 && len(l.keys) == 0:
+		return nil, l.arityError(len(args))
+	}
+	return e, nil
+}
+
+// bindKeys binds the keyword params of the lambda in e from the :name value
+// pairs of args.
+func (l *lambda) bindKeys(e *env, args []*object) error {
+	if len(args)%2 != 0 {
+		return fmt.Errorf("expected :name value pairs of keyword arguments, got %s", newObject(args))
+	}
+	vs := map[string]*object{}
+	for i := 0; i < len(args); i += 2 {
+		k := args[i]
+		if k == nil {
+			return errors.New("expected keyword, got <nothing>")
+		}
+		if k.t != TYPE_SYMBOL || !isKeyword(k.s) {
+			return fmt.Errorf("expected keyword, got %s", k)
+		}
+		vs[k.s[1:]] = args[i+1]
+	}
+
+	for _, p := range l.keys {
+		v, ok := vs[p.name]
+		if !ok {
+			if err := l.bindDefault(e, p); err != nil {
+				return err
+			}
+			continue
+		}
+		e.define(p.name, v)
+		delete(vs, p.name)
+	}
+	if l.rest == "" {
+		for k := range vs {
+			return fmt.Errorf("unexpected keyword :%s", k)
+		}
+	}
+	return nil
+}
+
+// bindDefault binds p in e to the value of its default.
+func (l *lambda) bindDefault(e *env, p param) error {
+	var v *object
+	if p.def != nil {
+		var err error
+		if v, err = eval(e, p.def); err != nil {
+			return err
+		}
+	}
+	e.define(p.name, v)
+	return nil
+}
+
+func (l *lambda) call(args ...*object) (*object, error) {
+	e, err := l.bind(args...)
+	if err != nil {
+		return nil, err
+	}
 	return eval(e, l.body)
 }
@@ -8,6 +8,7 @@ import (
 )
 
 func TestNewLambda(t *testing.T) {
+	e := newGlobalEnv()
 	cases := []struct {
 		params, body *object
 		env          *env
@@ -18,25 +19,73 @@ func TestNewLambda(t *testing.T) {
 			wantErr: errors.New("nil params or body"),
 		},
 		{
-			params:  newObject("foo"),
-			body: newObject(42),
+			params:  newObject(42),
+			body:    newObject(42),
 			wantErr: errors.New("invalid params. expected list."),
 		},
 		{
 			params:  newObject([]*object{newObject(42)}),
-			body: newObject(42),
+			body:    newObject(42),
 			wantErr: fmt.Errorf("unexpected non-symbolic param: %s", "42"),
 		},
 		{
 			params: newObject([]*object{newObject("foo")}),
 			body:   newObject(42),
-			env:    &globalEnv,
+			env:    e,
+			want: &lambda{
+				params:   newObject([]*object{newObject("foo")}),
+				body:     newObject(42),
+				outer:    e,
+				required: []string{"foo"},
+			},
+		},
+		{
+			params: newObject("args"),
+			body:   newObject(42),
 			want: &lambda{
-				newObject([]*object{newObject("foo")}),
-				newObject(42),
-				&globalEnv,
+				params: newObject("args"),
+				body:   newObject(42),
+				rest:   "args",
 			},
 		},
+		{
+			params: newObject([]*object{
+				newObject("a"),
+				newObject("&optional"),
+				newObject([]*object{newObject("b"), newObject(1)}),
+				newObject("&key"),
+				newObject("c"),
+				newObject("."),
+				newObject("d"),
+			}),
+			body: newObject(42),
+			want: &lambda{
+				params: newObject([]*object{
+					newObject("a"),
+					newObject("&optional"),
+					newObject([]*object{newObject("b"), newObject(1)}),
+					newObject("&key"),
+					newObject("c"),
+					newObject("."),
+					newObject("d"),
+				}),
+				body:     newObject(42),
+				required: []string{"a"},
+				optional: []param{{"b", newObject(1)}},
+				keys:     []param{{"c", nil}},
+				rest:     "d",
+			},
+		},
+		{
+			params:  newObject([]*object{newObject("."), newObject("a"), newObject("b")}),
+			body:    newObject(42),
+			wantErr: errors.New("expected one symbol after . in params"),
+		},
+		{
+			params:  newObject([]*object{newObject([]*object{newObject("a"), newObject(1)})}),
+			body:    newObject(42),
+			wantErr: errors.New("unexpected non-symbolic param: (a 1)"),
+		},
 	}
 
 	for _, tt := range cases {
@@ -49,3 +98,85 @@ func TestNewLambda(t *testing.T) {
 		}
 	}
 }
+
+func TestLambdaParams(t *testing.T) {
+	cases := []evalTest{
+		{
+			name:    "rest",
+			program: "((lambda (a b . rest) (list a b rest)) 1 2 3 4)",
+			want:    "(1 2 (3 4))",
+		},
+		{
+			name:    "empty rest",
+			program: "((lambda (a . rest) rest) 1)",
+			want:    "()",
+		},
+		{
+			name:    "all rest",
+			program: "((lambda args args) 1 2)",
+			want:    "(1 2)",
+		},
+		{
+			name:    "&rest",
+			program: "((lambda (a &rest r) r) 1 2)",
+			want:    "(2)",
+		},
+		{
+			name:    "optional",
+			program: "(define f (lambda (a &optional (b (* a 10)) c) (list a b c))) (list (f 1) (f 1 2 3))",
+			want:    "((1 10 #<void>) (1 2 3))",
+		},
+		{
+			name:    "key",
+			program: "(define f (lambda (a &key (b 2) (c 3)) (list a b c))) (list (f 1) (f 1 :c 30))",
+			want:    "((1 2 3) (1 2 30))",
+		},
+		{
+			name:    "keyword",
+			program: ":foo",
+			want:    ":foo",
+		},
+		{
+			name:    "too few",
+			program: "(define f (lambda (a b) a)) (f 1)",
+			wantErr: "expected two arguments to f, got 1",
+		},
+		{
+			name:    "too many optional",
+			program: "(define f (lambda (a &optional b) a)) (f 1 2 3)",
+			wantErr: "expected between 1 and 2 arguments to f, got 3",
+		},
+		{
+			name:    "too few rest",
+			program: "(define f (lambda (a . r) a)) (f)",
+			wantErr: "expected at least one argument to f, got 0",
+		},
+		{
+			name:    "anonymous",
+			program: "((lambda (a) a))",
+			wantErr: "expected one argument to lambda, got 0",
+		},
+		{
+			name:    "named let",
+			program: "(let loop ((a 1)) (loop))",
+			wantErr: "expected one argument to loop, got 0",
+		},
+		{
+			name:    "unknown keyword",
+			program: "((lambda (&key a) a) :b 1)",
+			wantErr: "unexpected keyword :b",
+		},
+		{
+			name:    "no value for keyword",
+			program: "(define (f &key a) a) (f (begin) 1)",
+			wantErr: "expected keyword, got <nothing>",
+		},
+		{
+			name:    "odd keywords",
+			program: "((lambda (&key a) a) :a)",
+			wantErr: "expected :name value pairs of keyword arguments, got (:a)",
+		},
+	}
+
+	runEvalTests(t, cases)
+}
@@ -0,0 +1,603 @@
+package golisp
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// macro is a syntax-rules or defmacro transformer.
+//
+// Expansions are hygienic in that names bound by a template, such as the
+// params of a lambda or the names of a let, are renamed afresh for each
+// expansion so they cannot capture names passed to the macro. Free names in a
+// template refer to whatever they are bound to where the macro is used.
+type macro struct {
+	name string
+	// proc, if set, is the procedure of a defmacro, which is called with the
+	// unevaluated arguments of a use and returns its expansion. It is not
+	// hygienic.
+	proc *lambda
+
+	ellipsis string
+	literals map[string]bool
+	rules    []rule
+}
+
+// rule is a pattern and the template it expands to. binders are the names
+// bound by the template, which are renamed on expansion.
+type rule struct {
+	pattern  *object
+	template *object
+	binders  map[string]bool
+}
+
+// match is the part of a form matched by a pattern variable. Variables
+// followed by an ellipsis match a sequence.
+type match struct {
+	v    *object
+	many bool
+	seq  []*match
+}
+
+type bindings map[string]*match
+
+// renames counts renamed binders so that each has a fresh name.
+var renames int64
+
+// newMacro returns the macro called name defined by the syntax-rules form x.
+//
+//	(syntax-rules (literal ...) (pattern template) ...)
+//	(syntax-rules ellipsis (literal ...) (pattern template) ...)
+func newMacro(name string, x *object) (*macro, error) {
+	if x.t != TYPE_LIST || len(x.l) == 0 || x.l[0].t != TYPE_BUILTIN || x.l[0].s != "syntax-rules" {
+		return nil, fmt.Errorf("expected syntax-rules for %s, got %s", name, x)
+	}
+	m := &macro{name: name, ellipsis: "...", literals: map[string]bool{}}
+	rest := x.l[1:]
+	if len(rest) > 0 && rest[0].t == TYPE_SYMBOL {
+		m.ellipsis, rest = rest[0].s, rest[1:]
+	}
+	if len(rest) == 0 || rest[0].t != TYPE_LIST {
+		return nil, fmt.Errorf("expected list of literals in syntax-rules for %s", name)
+	}
+	for _, l := range rest[0].l {
+		if l.t != TYPE_SYMBOL {
+			return nil, fmt.Errorf("expected symbol as literal in syntax-rules for %s, got %s", name, l)
+		}
+		m.literals[l.s] = true
+	}
+
+	for _, r := range rest[1:] {
+		if r.t != TYPE_LIST || len(r.l) != 2 || r.l[0].t != TYPE_LIST || len(r.l[0].l) == 0 {
+			return nil, fmt.Errorf("expected ((_ pattern ...) template) rule in syntax-rules for %s, got %s", name, r)
+		}
+		vars := map[string]bool{}
+		m.vars(r.l[0], vars)
+		binders := map[string]bool{}
+		templateBinders(r.l[1], vars, binders)
+		// The keyword position of the pattern is ignored.
+		m.rules = append(m.rules, rule{newObject(r.l[0].l[1:]), r.l[1], binders})
+	}
+	return m, nil
+}
+
+// isEllipsis returns true if x is the macro's ellipsis.
+func (m *macro) isEllipsis(x *object) bool {
+	return x.t == TYPE_SYMBOL && x.s == m.ellipsis
+}
+
+// vars adds the pattern variables in p to vars.
+func (m *macro) vars(p *object, vars map[string]bool) {
+	switch p.t {
+	case TYPE_SYMBOL:
+		if p.s != "_" && p.s != "." && !m.isEllipsis(p) && !m.literals[p.s] {
+			vars[p.s] = true
+		}
+	case TYPE_LIST:
+		for _, q := range p.l {
+			m.vars(q, vars)
+		}
+	}
+}
+
+// templateBinders adds the names bound by lambda, define and let forms in the
+// template t, other than pattern variables, to binders.
+func templateBinders(t *object, vars, binders map[string]bool) {
+	if t.t != TYPE_LIST || len(t.l) == 0 {
+		return
+	}
+	add := func(p *object) {
+		if p.t == TYPE_LIST && len(p.l) > 0 {
+			p = p.l[0]
+		}
+		if p.t == TYPE_SYMBOL && !vars[p.s] && p.s != "." && p.s != "..." && p.s[0] != '&' {
+			binders[p.s] = true
+		}
+	}
+	addAll := func(ps *object) {
+		if ps.t != TYPE_LIST {
+			add(ps)
+			return
+		}
+		for _, p := range ps.l {
+			add(p)
+		}
+	}
+
+	if h := t.l[0]; h.t == TYPE_BUILTIN && len(t.l) > 1 {
+		switch h.s {
+		case "lambda":
+			addAll(t.l[1])
+		case "define":
+			// Unwrap any procedure shorthand to the name and params.
+			target := t.l[1]
+			for target.t == TYPE_LIST && len(target.l) > 0 {
+				addAll(newObject(target.l[1:]))
+				target = target.l[0]
+			}
+			add(target)
+		case "let", "let*", "letrec", "letrec*":
+			bs := t.l[1]
+			if bs.t == TYPE_SYMBOL && len(t.l) > 2 {
+				add(bs)
+				bs = t.l[2]
+			}
+			addAll(bs)
+		}
+	}
+	for _, u := range t.l {
+		templateBinders(u, vars, binders)
+	}
+}
+
+// expand returns the expansion of the macro use x by the first rule whose
+// pattern matches it.
+func (m *macro) expand(x *object) (*object, error) {
+	if m.proc != nil {
+		args := make([]*object, len(x.l)-1)
+		for i, a := range x.l[1:] {
+			args[i] = toData(a)
+		}
+		res, err := m.proc.call(args...)
+		if err != nil {
+			return nil, err
+		}
+		if res, err = toSyntax(res); err != nil {
+			return nil, fmt.Errorf("expansion of %s: %s", m.name, err)
+		}
+		log.Printf("expanded %s to %s\n", x, res)
+		return res, nil
+	}
+
+	args := newObject(x.l[1:])
+	for _, r := range m.rules {
+		b := bindings{}
+		if !m.match(r.pattern, args, b) {
+			continue
+		}
+		renamed := map[string]*object{}
+		for name := range r.binders {
+			renamed[name] = newObject(fmt.Sprintf("%s.%d", name, atomic.AddInt64(&renames, 1)))
+		}
+		res, err := m.transcribe(r.template, b, renamed, true)
+		if err != nil {
+			return nil, err
+		}
+		if res != nil && res.t == TYPE_LIST && res.span == nil {
+			res.span = x.span
+		}
+		log.Printf("expanded %s to %s\n", x, res)
+		return res, nil
+	}
+	return nil, fmt.Errorf("no syntax-rules pattern of %s matches %s", m.name, x)
+}
+
+// match matches the pattern p against x, adding pattern variables to b.
+func (m *macro) match(p, x *object, b bindings) bool {
+	switch p.t {
+	case TYPE_SYMBOL:
+		switch {
+		case p.s == "_":
+			return true
+		case m.literals[p.s]:
+			return x != nil && x.t == TYPE_SYMBOL && x.s == p.s
+		}
+		b[p.s] = &match{v: x}
+		return true
+	case TYPE_LIST:
+		if x == nil || x.t != TYPE_LIST {
+			return false
+		}
+		return m.matchList(p.l, x.l, b)
+	}
+	return equal(p, x)
+}
+
+// matchList matches the elements of a list pattern against the elements of a
+// list, allowing for one element followed by an ellipsis, matching any number
+// of elements, or a final . rest, matching the remaining elements.
+func (m *macro) matchList(ps, xs []*object, b bindings) bool {
+	for i, p := range ps {
+		if p.t == TYPE_SYMBOL && p.s == "." && i == len(ps)-2 {
+			return len(xs) >= i && m.match(ps[i+1], newObject(xs[i:]), b)
+		}
+		if i+1 < len(ps) && m.isEllipsis(ps[i+1]) {
+			after := ps[i+2:]
+			n := len(xs) - i - len(after)
+			if n < 0 {
+				return false
+			}
+			vars := map[string]bool{}
+			m.vars(p, vars)
+			seqs := map[string]*match{}
+			for v := range vars {
+				seqs[v] = &match{many: true}
+			}
+			for _, x := range xs[i : i+n] {
+				bi := bindings{}
+				if !m.match(p, x, bi) {
+					return false
+				}
+				for v := range vars {
+					seqs[v].seq = append(seqs[v].seq, bi[v])
+				}
+			}
+			for v, s := range seqs {
+				b[v] = s
+			}
+			return m.matchList(after, xs[i+n:], b)
+		}
+		if i >= len(xs) || !m.match(p, xs[i], b) {
+			return false
+		}
+	}
+	return len(ps) == len(xs)
+}
+
+// transcribe returns the template t with pattern variables replaced by what
+// they matched and binders renamed. An element followed by an ellipsis is
+// repeated for each match of the sequence variables within it. (... ...)
+// escapes an ellipsis.
+func (m *macro) transcribe(t *object, b bindings, renamed map[string]*object, ellipsis bool) (*object, error) {
+	switch t.t {
+	case TYPE_SYMBOL:
+		if v, ok := b[t.s]; ok {
+			if v.many {
+				return nil, fmt.Errorf("expected ellipsis after %s in template of %s", t.s, m.name)
+			}
+			return v.v, nil
+		}
+		if r, ok := renamed[t.s]; ok {
+			return r, nil
+		}
+		return t, nil
+	case TYPE_LIST:
+	default:
+		return t, nil
+	}
+
+	if ellipsis && len(t.l) == 2 && m.isEllipsis(t.l[0]) {
+		return m.transcribe(t.l[1], b, renamed, false)
+	}
+	l := []*object{}
+	for i := 0; i < len(t.l); i++ {
+		u := t.l[i]
+		if !ellipsis || i+1 >= len(t.l) || !m.isEllipsis(t.l[i+1]) {
+			v, err := m.transcribe(u, b, renamed, ellipsis)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			continue
+		}
+
+		i++
+		vars := map[string]bool{}
+		m.vars(u, vars)
+		n := -1
+		for v := range vars {
+			if s, ok := b[v]; ok && s.many {
+				if n >= 0 && len(s.seq) != n {
+					return nil, fmt.Errorf("mismatched ellipsis lengths in template of %s", m.name)
+				}
+				n = len(s.seq)
+			} else {
+				delete(vars, v)
+			}
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("expected pattern variable before ellipsis in template of %s", m.name)
+		}
+		for j := 0; j < n; j++ {
+			bj := bindings{}
+			for k, v := range b {
+				bj[k] = v
+			}
+			for v := range vars {
+				bj[v] = b[v].seq[j]
+			}
+			v, err := m.transcribe(u, bj, renamed, ellipsis)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+	}
+	return newObject(l), nil
+}
+
+// lookupMacro returns the macro bound to name in e, if any.
+func lookupMacro(e *env, name string) *macro {
+	ee, err := e.find(name)
+	if err != nil {
+		return nil
+	}
+	if o := ee.m[name]; o != nil && o.t == TYPE_MACRO {
+		return o.macro
+	}
+	return nil
+}
+
+// defineSyntax binds the macros of a define-syntax form x in e.
+//
+//	(define-syntax name (syntax-rules ...))
+func defineSyntax(e *env, x *object) error {
+	if err := checkForm(x, 2, 2); err != nil {
+		return err
+	}
+	if x.l[1].t != TYPE_SYMBOL {
+		return fmt.Errorf("expected symbol to define-syntax, got %s", x.l[1])
+	}
+	m, err := newMacro(x.l[1].s, x.l[2])
+	if err != nil {
+		return err
+	}
+	e.define(m.name, newObject(m))
+	return nil
+}
+
+// defmacro binds the macro of a defmacro form x in e.
+//
+//	(defmacro name (params ...) body ...)
+func defmacro(e *env, x *object) error {
+	if err := checkForm(x, 3, -1); err != nil {
+		return err
+	}
+	if x.l[1].t != TYPE_SYMBOL {
+		return fmt.Errorf("expected symbol to defmacro, got %s", x.l[1])
+	}
+	l, err := makeLambda(e, x.l[2], x.l[3:])
+	if err != nil {
+		return err
+	}
+	l.name = x.l[1].s
+	e.define(l.name, newObject(&macro{name: l.name, proc: l}))
+	return nil
+}
+
+// expand returns x with all macro uses within it expanded, so that it may be
+// evaluated in e. Macros defined by define-syntax are bound in e, or in a
+// scope within it for those in bodies.
+func expand(e *env, x *object) (*object, error) {
+	for {
+		if x == nil || x.t != TYPE_LIST || len(x.l) == 0 {
+			return x, nil
+		}
+		h := x.l[0]
+		if h.t == TYPE_SYMBOL {
+			if m := lookupMacro(e, h.s); m != nil {
+				ex, err := m.expand(x)
+				if err != nil {
+					return nil, errorAt(x, err)
+				}
+				x = ex
+				continue
+			}
+		}
+		if h.t != TYPE_BUILTIN {
+			return expandEach(e, x, 0)
+		}
+
+		switch h.s {
+		case "quote":
+			// Convert the datum once rather than each time it is evaluated.
+			if len(x.l) != 2 {
+				return x, nil
+			}
+			return &object{t: TYPE_LIST, l: []*object{h, toData(x.l[1])}, span: x.span}, nil
+		case "quasiquote", "syntax-rules":
+			return x, nil
+		case "defmacro":
+			if err := defmacro(e, x); err != nil {
+				return nil, errorAt(x, err)
+			}
+			return x, nil
+		case "define-syntax":
+			if err := defineSyntax(e, x); err != nil {
+				return nil, errorAt(x, err)
+			}
+			return x, nil
+		case "let-syntax", "letrec-syntax":
+			return expandLetSyntax(e, x)
+		case "lambda":
+			inner := newScope(e)
+			if len(x.l) > 1 {
+				bindParams(inner, x.l[1])
+			}
+			return expandEach(inner, x, 2)
+		case "define":
+			if len(x.l) > 1 && x.l[1].t == TYPE_LIST {
+				inner := newScope(e)
+				bindFormals(inner, x.l[1])
+				return expandEach(inner, x, 2)
+			}
+			return expandEach(e, x, 2)
+		case "let", "let*", "letrec", "letrec*":
+			return expandLet(e, x)
+		case "cond":
+			return expandClauses(e, x, 1, 0)
+		case "guard":
+			return expandGuard(e, x)
+		case "case":
+			return expandClauses(e, x, 2, 1)
+		}
+		return expandEach(e, x, 1)
+	}
+}
+
+// bindParams binds the names of the lambda params in the scope e being
+// expanded, so that they shadow any macros of the same name.
+func bindParams(e *env, params *object) {
+	switch {
+	case params == nil:
+	case params.t == TYPE_SYMBOL:
+		e.define(params.s, nil)
+	case params.t == TYPE_LIST:
+		for _, p := range params.l {
+			if p.t == TYPE_LIST && len(p.l) != 0 {
+				p = p.l[0]
+			}
+			if p.t == TYPE_SYMBOL && p.s != "." && !strings.HasPrefix(p.s, "&") {
+				e.define(p.s, nil)
+			}
+		}
+	}
+}
+
+// bindFormals binds the params of the shorthand define formals (name params
+// ...), which may be curried, in the scope e being expanded.
+func bindFormals(e *env, formals *object) {
+	for formals != nil && formals.t == TYPE_LIST && len(formals.l) != 0 {
+		bindParams(e, &object{t: TYPE_LIST, l: formals.l[1:]})
+		formals = formals.l[0]
+	}
+}
+
+// expandEach returns a copy of x with the elements from the i'th on expanded.
+func expandEach(e *env, x *object, i int) (*object, error) {
+	l := make([]*object, len(x.l))
+	copy(l, x.l)
+	for ; i < len(l); i++ {
+		var err error
+		if l[i], err = expand(e, l[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &object{t: TYPE_LIST, l: l, span: x.span}, nil
+}
+
+// expandLet expands the binding values and body of a let form x.
+func expandLet(e *env, x *object) (*object, error) {
+	i := 1
+	if len(x.l) > 1 && x.l[1].t == TYPE_SYMBOL {
+		i++
+	}
+	if len(x.l) <= i || x.l[i].t != TYPE_LIST {
+		// Leave the error to eval.
+		return x, nil
+	}
+	// The names bound by the let shadow any macros of the same name in its
+	// body, and in its values too unless it is a plain let.
+	inner := newScope(e)
+	if i == 2 {
+		inner.define(x.l[1].s, nil)
+	}
+	for _, b := range x.l[i].l {
+		if b.t == TYPE_LIST && len(b.l) != 0 && b.l[0].t == TYPE_SYMBOL {
+			inner.define(b.l[0].s, nil)
+		}
+	}
+	scope := e
+	if x.l[0].s != "let" {
+		scope = inner
+	}
+	bs := &object{t: TYPE_LIST, l: make([]*object, len(x.l[i].l)), span: x.l[i].span}
+	for j, b := range x.l[i].l {
+		if b.t != TYPE_LIST {
+			// Leave the error to eval.
+			bs.l[j] = b
+			continue
+		}
+		var err error
+		if bs.l[j], err = expandEach(scope, b, 1); err != nil {
+			return nil, err
+		}
+	}
+	res, err := expandEach(inner, x, i+1)
+	if err != nil {
+		return nil, err
+	}
+	res.l[i] = bs
+	return res, nil
+}
+
+// expandClauses expands the elements of x from the i'th on as cond or case
+// clauses, skipping the first skip elements of each clause.
+func expandClauses(e *env, x *object, i, skip int) (*object, error) {
+	l := make([]*object, len(x.l))
+	copy(l, x.l)
+	for j := 1; j < len(l); j++ {
+		var err error
+		switch {
+		case j < i:
+			l[j], err = expand(e, l[j])
+		case l[j].t == TYPE_LIST:
+			l[j], err = expandEach(e, l[j], skip)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &object{t: TYPE_LIST, l: l, span: x.span}, nil
+}
+
+// expandGuard expands the clauses and body of a guard form x.
+func expandGuard(e *env, x *object) (*object, error) {
+	if len(x.l) < 2 || x.l[1].t != TYPE_LIST {
+		// Leave the error to eval.
+		return x, nil
+	}
+	res, err := expandEach(newScope(e), x, 2)
+	if err != nil {
+		return nil, err
+	}
+	if res.l[1], err = expandClauses(e, x.l[1], 1, 0); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// expandLetSyntax binds the macros of a let-syntax or letrec-syntax form x in
+// a new scope and returns its expanded body as a let without bindings.
+//
+//	(let-syntax ((name (syntax-rules ...)) ...) body ...)
+func expandLetSyntax(e *env, x *object) (*object, error) {
+	name := x.l[0].s
+	if err := checkForm(x, 2, -1); err != nil {
+		return nil, errorAt(x, err)
+	}
+	bs, err := parseBindings(name, x.l[1])
+	if err != nil {
+		return nil, errorAt(x, err)
+	}
+	inner := newScope(e)
+	for _, b := range bs {
+		m, err := newMacro(b.name, b.exp)
+		if err != nil {
+			return nil, errorAt(x, err)
+		}
+		inner.define(b.name, newObject(m))
+	}
+
+	l := []*object{newObject("let"), newObject([]*object{})}
+	for _, f := range x.l[2:] {
+		f, err := expand(inner, f)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, f)
+	}
+	return &object{t: TYPE_LIST, l: l, span: x.span}, nil
+}
@@ -0,0 +1,202 @@
+package golisp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMacros(t *testing.T) {
+	cases := []evalTest{
+		{
+			name: "swap",
+			program: `(define-syntax swap!
+				(syntax-rules ()
+					((_ a b) (let ((tmp a)) (set! a b) (set! b tmp)))))
+				(define tmp 1)
+				(define y 2)
+				(swap! tmp y)
+				(list tmp y)`,
+			want: "(2 1)",
+		},
+		{
+			name: "recursive",
+			program: `(define-syntax my-or
+				(syntax-rules ()
+					((_) 0)
+					((_ e) e)
+					((_ e r ...) (let ((t e)) (if t t (my-or r ...))))))
+				(define t 5)
+				(list (my-or) (my-or 0 t) (my-or 0 0 7))`,
+			want: "(0 5 7)",
+		},
+		{
+			name: "literals",
+			program: `(define-syntax for
+				(syntax-rules (in)
+					((_ x in l body ...) (map (lambda (x) body ...) l))))
+				(for x in (list 1 2 3) (* x x))`,
+			want: "(1 4 9)",
+		},
+		{
+			name: "nested ellipsis",
+			program: `(define-syntax my-let
+				(syntax-rules ()
+					((_ ((n v) ...) b ...) ((lambda (n ...) b ...) v ...))))
+				(my-let ((a 1) (b 2)) (+ a b))`,
+			want: "3",
+		},
+		{
+			name: "ellipsis depth",
+			program: `(define-syntax flat
+				(syntax-rules ()
+					((_ (a ...) ...) (list a ... ...))))
+				(flat (1 2) (3))`,
+			wantErr: "expected ellipsis after a in template of flat",
+		},
+		{
+			name: "rest",
+			program: `(define-syntax first
+				(syntax-rules ()
+					((_ a . rest) a)))
+				(first 1 2 3)`,
+			want: "1",
+		},
+		{
+			name: "custom ellipsis",
+			program: `(define-syntax my-list
+				(syntax-rules ::: ()
+					((_ a :::) (list a :::))))
+				(my-list 1 2)`,
+			want: "(1 2)",
+		},
+		{
+			name: "used before definition",
+			program: `(define (f x) (inc! x) x)
+				(define-syntax inc!
+					(syntax-rules () ((_ v) (set! v (+ v 1)))))
+				(f 1)`,
+			want: "2",
+		},
+		{
+			name: "in body",
+			program: `(define (f x)
+					(define-syntax double (syntax-rules () ((_ v) (* 2 v))))
+					(double x))
+				(f 4)`,
+			want: "8",
+		},
+		{
+			name: "let-syntax",
+			program: `(let-syntax ((double (syntax-rules () ((_ v) (* 2 v)))))
+					(double 3))`,
+			want: "6",
+		},
+		{
+			name: "let-syntax scope",
+			program: `(let-syntax ((double (syntax-rules () ((_ v) (* 2 v)))))
+					(double 3))
+				(double 3)`,
+			wantErr: `"double" not found`,
+		},
+		{
+			name: "macroexpand",
+			program: `(define-syntax my-if
+				(syntax-rules ()
+					((_ c a b) (cond (c a) (else b)))))
+				(macroexpand (quote (my-if 1 (my-if 0 2 3) 4)))`,
+			want: "(cond (1 (cond (0 2) (else 3))) (else 4))",
+		},
+		{
+			name: "no match",
+			program: `(define-syntax swap!
+				(syntax-rules ()
+					((_ a b) (let ((tmp a)) (set! a b) (set! b tmp)))))
+				(swap! 1)`,
+			wantErr: "no syntax-rules pattern of swap! matches (swap! 1)",
+		},
+		{
+			name: "defmacro",
+			program: `(defmacro my-unless (test . body)
+					"Evaluates body unless test is true."
+					` + "`" + `(if ,test 0 (begin ,@body)))
+				(list (my-unless 0 1 2) (my-unless 1 2))`,
+			want: "(2 0)",
+		},
+		{
+			name: "defmacro capture",
+			program: `(defmacro with-it (v . body) ` + "`" + `(let ((it ,v)) ,@body))
+				(with-it (+ 1 2) (* it it))`,
+			want: "9",
+		},
+		{
+			name: "defmacro expand",
+			program: `(defmacro inc! (v &optional (n 1)) ` + "`" + `(set! ,v (+ ,v ,n)))
+				(macroexpand '(inc! x))`,
+			want: "(set! x (+ x 1))",
+		},
+		{
+			name:    "shadowed by define param",
+			program: "(define-syntax m (syntax-rules () ((_ x) (* x 2)))) (define (g m) (m 3)) (g (lambda (n) (- 0 n)))",
+			want:    "-3",
+		},
+		{
+			name:    "shadowed by lambda param",
+			program: "(define-syntax m (syntax-rules () ((_ x) (* x 2)))) ((lambda (a &optional (m list)) (m a)) 3)",
+			want:    "(3)",
+		},
+		{
+			name:    "shadowed by let",
+			program: "(define-syntax m (syntax-rules () ((_ x) (* x 2)))) (list (let ((m list)) (m 3)) (letrec* ((m list) (y (m 4))) y) (m 3))",
+			want:    "((3) (4) 6)",
+		},
+		{
+			name:    "defmacro arity",
+			program: "(defmacro m (a) a) (m)",
+			wantErr: "expected one argument to m, got 0",
+		},
+		{
+			name:    "defmacro no value",
+			program: "(defmacro m () (if #f #f)) (m)",
+			wantErr: "1:28: expansion of m: expected form, got <nothing>",
+		},
+		{
+			name:    "defmacro no value element",
+			program: "(defmacro m () (list '+ 1 (if #f #f))) (m)",
+			wantErr: "1:40: expansion of m: expected form, got <nothing>",
+		},
+		{
+			name:    "bad rules",
+			program: "(define-syntax m (lambda (x) x))",
+			wantErr: "expected syntax-rules for m, got (lambda (x) x)",
+		},
+	}
+
+	runEvalTests(t, cases)
+}
+
+func TestMacroHygiene(t *testing.T) {
+	m, err := newMacro("swap!", mustRead(t, "(syntax-rules () ((_ a b) (let ((tmp a)) (set! a b) (set! b tmp))))"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.expand(mustRead(t, "(swap! tmp other)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The let's tmp is renamed and the tmp passed in is not.
+	tmp := got.l[1].l[0].l[0].s
+	if tmp == "tmp" || !strings.HasPrefix(tmp, "tmp.") {
+		t.Errorf("got binder %q, want it renamed", tmp)
+	}
+	if got.l[2].l[1].s != "tmp" || got.l[3].l[2].s != tmp {
+		t.Errorf("got %s, want passed tmp kept and introduced tmp renamed", got)
+	}
+}
+
+func mustRead(t *testing.T, src string) *object {
+	forms, err := readForms("", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return forms[0]
+}
@@ -0,0 +1,305 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Numbers are exact or inexact. Exact numbers are integers, held in an int64
+// until they overflow and in a big.Int after, and rationals, held in a
+// big.Rat. Inexact numbers are float64s. An operation on exact numbers gives
+// an exact result, and one involving an inexact number gives an inexact
+// result. Exact results are normalized so that a number has only one
+// representation: a rational is never a whole number and a big integer never
+// fits in an int64.
+
+var errDivideByZero = errors.New("division by zero")
+
+// maxExptBits bounds the size in bits of an exact result of expt, so that a
+// large exponent fails rather than exhausting memory.
+const maxExptBits = 1 << 20
+
+// isNumber reports whether o is a number.
+func isNumber(o *object) bool {
+	return o != nil && (o.t == TYPE_INT || o.t == TYPE_BIGINT || o.t == TYPE_RATIONAL || o.t == TYPE_FLOAT)
+}
+
+// isExact reports whether o is an exact number.
+func isExact(o *object) bool {
+	return isNumber(o) && o.t != TYPE_FLOAT
+}
+
+// newInt returns the exact integer n.
+func newInt(n *big.Int) *object {
+	if n.IsInt64() {
+		return newObject(n.Int64())
+	}
+	return &object{t: TYPE_BIGINT, big: n}
+}
+
+// newRat returns the exact number r.
+func newRat(r *big.Rat) *object {
+	if r.IsInt() {
+		return newInt(new(big.Int).Set(r.Num()))
+	}
+	return &object{t: TYPE_RATIONAL, rat: r}
+}
+
+// toRat returns the value of the exact number o.
+func (o *object) toRat() (*big.Rat, error) {
+	if o == nil {
+		return nil, errors.New("cannot convert nil to exact number")
+	}
+	switch o.t {
+	case TYPE_INT:
+		return new(big.Rat).SetInt64(o.i), nil
+	case TYPE_BIGINT:
+		return new(big.Rat).SetInt(o.big), nil
+	case TYPE_RATIONAL:
+		return o.rat, nil
+	}
+	return nil, fmt.Errorf("cannot convert %q to exact number", o.t)
+}
+
+// toExact returns the exact number nearest to the number o.
+func toExact(o *object) (*object, error) {
+	if isExact(o) {
+		return o, nil
+	}
+	f, err := o.toFloat()
+	if err != nil {
+		return nil, err
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("no exact representation of %s", o)
+	}
+	return newRat(new(big.Rat).SetFloat64(f)), nil
+}
+
+// arith is an arithmetic operation on two numbers: on int64s, returning false
+// if the result does not fit, on exact numbers, and on floats.
+type arith struct {
+	int64 func(a, b int64) (int64, bool)
+	exact func(a, b *big.Rat) (*big.Rat, error)
+	float func(a, b float64) float64
+}
+
+func (op arith) apply(a, b *object) (*object, error) {
+	fa, err := a.toFloat()
+	if err != nil {
+		return nil, err
+	}
+	fb, err := b.toFloat()
+	if err != nil {
+		return nil, err
+	}
+	if a.t == TYPE_FLOAT || b.t == TYPE_FLOAT {
+		return newObject(op.float(fa, fb)), nil
+	}
+	if a.t == TYPE_INT && b.t == TYPE_INT {
+		if n, ok := op.int64(a.i, b.i); ok {
+			return newObject(n), nil
+		}
+	}
+	ra, _ := a.toRat()
+	rb, _ := b.toRat()
+	r, err := op.exact(ra, rb)
+	if err != nil {
+		return nil, err
+	}
+	return newRat(r), nil
+}
+
+// fold applies op to the arguments in turn from the left.
+func (op arith) fold(o []*object) (*object, error) {
+	res := o[0]
+	for _, x := range o[1:] {
+		var err error
+		if res, err = op.apply(res, x); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+var (
+	addOp = arith{
+		int64: func(a, b int64) (int64, bool) {
+			s := a + b
+			return s, (s > a) == (b > 0)
+		},
+		exact: func(a, b *big.Rat) (*big.Rat, error) {
+			return new(big.Rat).Add(a, b), nil
+		},
+		float: func(a, b float64) float64 { return a + b },
+	}
+	subOp = arith{
+		int64: func(a, b int64) (int64, bool) {
+			d := a - b
+			return d, (d < a) == (b > 0)
+		},
+		exact: func(a, b *big.Rat) (*big.Rat, error) {
+			return new(big.Rat).Sub(a, b), nil
+		},
+		float: func(a, b float64) float64 { return a - b },
+	}
+	mulOp = arith{
+		int64: func(a, b int64) (int64, bool) {
+			if a == 0 || b == 0 {
+				return 0, true
+			}
+			hi, lo := bits.Mul64(uint64(abs64(a)), uint64(abs64(b)))
+			if hi != 0 || lo > math.MaxInt64 || a == math.MinInt64 || b == math.MinInt64 {
+				return 0, false
+			}
+			return a * b, true
+		},
+		exact: func(a, b *big.Rat) (*big.Rat, error) {
+			return new(big.Rat).Mul(a, b), nil
+		},
+		float: func(a, b float64) float64 { return a * b },
+	}
+	divOp = arith{
+		int64: func(a, b int64) (int64, bool) {
+			if b == 0 || a%b != 0 || (a == math.MinInt64 && b == -1) {
+				return 0, false
+			}
+			return a / b, true
+		},
+		exact: func(a, b *big.Rat) (*big.Rat, error) {
+			if b.Sign() == 0 {
+				return nil, errDivideByZero
+			}
+			return new(big.Rat).Quo(a, b), nil
+		},
+		float: func(a, b float64) float64 { return a / b },
+	}
+)
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// power returns a raised to the power b. It is exact if a is exact and b is an
+// exact integer.
+func power(a, b *object) (*object, error) {
+	fa, err := a.toFloat()
+	if err != nil {
+		return nil, err
+	}
+	fb, err := b.toFloat()
+	if err != nil {
+		return nil, err
+	}
+	if !isExact(a) || b.t != TYPE_INT {
+		return newObject(math.Pow(fa, fb)), nil
+	}
+	r, _ := a.toRat()
+	if r.Sign() == 0 && b.i < 0 {
+		return nil, errDivideByZero
+	}
+	size := r.Num().BitLen()
+	if d := r.Denom().BitLen(); d > size {
+		size = d
+	}
+	if e := abs64(b.i); size > 1 && (e < 0 || e > maxExptBits/int64(size)) {
+		return nil, fmt.Errorf("exponent %d too large for exact %s", b.i, a)
+	}
+	n := big.NewInt(abs64(b.i))
+	num := new(big.Int).Exp(r.Num(), n, nil)
+	den := new(big.Int).Exp(r.Denom(), n, nil)
+	if b.i < 0 {
+		num, den = den, num
+	}
+	return newRat(new(big.Rat).SetFrac(num, den)), nil
+}
+
+// compare returns -1, 0 or 1 as the number a is less than, equal to or
+// greater than the number b.
+func compare(a, b *object) (int, error) {
+	fa, err := a.toFloat()
+	if err != nil {
+		return 0, err
+	}
+	fb, err := b.toFloat()
+	if err != nil {
+		return 0, err
+	}
+	if a.t == TYPE_FLOAT || b.t == TYPE_FLOAT {
+		switch {
+		case fa < fb:
+			return -1, nil
+		case fa > fb:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	ra, _ := a.toRat()
+	rb, _ := b.toRat()
+	return ra.Cmp(rb), nil
+}
+
+// formatFloat returns the shortest representation of f that reads back as f.
+// Whole numbers keep a .0 so that they read back as inexact.
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// parseNumber returns the number written as s, or nil if s is not a number.
+// Integers may be written in binary, octal or hex with a #b, #o or #x prefix,
+// and rationals as n/d. It is an error for the denominator to be zero.
+func parseNumber(s string) (*object, error) {
+	base := 10
+	if len(s) > 2 && s[0] == '#' {
+		switch s[1] {
+		case 'b':
+			base = 2
+		case 'o':
+			base = 8
+		case 'd':
+			base = 10
+		case 'x':
+			base = 16
+		default:
+			return nil, nil
+		}
+		s = s[2:]
+	}
+	if i, err := strconv.ParseInt(s, base, 64); err == nil {
+		return newObject(i), nil
+	}
+	if n, ok := new(big.Int).SetString(s, base); ok {
+		return newInt(n), nil
+	}
+	if base != 10 {
+		return nil, nil
+	}
+	if strings.Count(s, "/") == 1 && !strings.ContainsAny(s, ".eE") {
+		if r, ok := new(big.Rat).SetString(s); ok {
+			return newRat(r), nil
+		}
+		nd := strings.Split(s, "/")
+		_, nok := new(big.Int).SetString(nd[0], 10)
+		d, dok := new(big.Int).SetString(nd[1], 10)
+		if nok && dok && d.Sign() == 0 {
+			return nil, errDivideByZero
+		}
+		return nil, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return newObject(f), nil
+	}
+	return nil, nil
+}
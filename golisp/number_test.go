@@ -0,0 +1,83 @@
+package golisp
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestNumbers(t *testing.T) {
+	cases := []evalTest{
+		{program: "(/ 1 3)", want: "1/3"},
+		{program: "(/ 6 3)", want: "2"},
+		{program: "(/ 1.0 4)", want: "0.25"},
+		{program: "(+ 1/3 2/3)", want: "1"},
+		{program: "(* 2/3 3/4)", want: "1/2"},
+		{program: "(- 1/2 1)", want: "-1/2"},
+		{program: "(+ 1/2 0.5)", want: "1.0"},
+		{program: "(* 9223372036854775807 2)", want: "18446744073709551614"},
+		{program: "(+ 9223372036854775807 1)", want: "9223372036854775808"},
+		{program: "(- -9223372036854775808 1)", want: "-9223372036854775809"},
+		{program: "(- (+ 9223372036854775807 1) 1)", want: "9223372036854775807"},
+		{program: "(* 100000000000 100000000000)", want: "10000000000000000000000"},
+		{program: "123456789012345678901234567890", want: "123456789012345678901234567890"},
+		{program: "(expt 2 100)", want: "1267650600228229401496703205376"},
+		{program: "(expt 2/3 -2)", want: "9/4"},
+		{program: "(expt 4 0.5)", want: "2.0"},
+		{program: "(abs -1/2)", want: "1/2"},
+		{program: "(list #x1F #xff #b1010 #o17 #d42 -5/10)", want: "(31 255 10 15 42 -1/2)"},
+		{program: "(> 1e100 (expt 10 99))", want: "#t"},
+		{program: "(list (< 1/3 0.34) (= 1/2 0.5) (= 1/3 2/6) (>= 2 3/2))", want: "(#t #t #t #t)"},
+		{program: "(list (exact? 1) (exact? 1/3) (exact? (expt 2 70)) (exact? 1.5))", want: "(#t #t #t #f)"},
+		{program: "(list (inexact? 1.5) (inexact? 1))", want: "(#t #f)"},
+		{program: "(exact->inexact 1/4)", want: "0.25"},
+		{program: "(exact->inexact 1/3)", want: "0.3333333333333333"},
+		{program: "(list 1e100 1e-10 2.0 -0.5)", want: "(1e+100 1e-10 2.0 -0.5)"},
+		{program: "(list (inexact->exact 0.5) (inexact->exact 2.0) (inexact->exact 7))", want: "(1/2 2 7)"},
+		{program: "(list (number? 1/2) (number? (expt 2 70)))", want: "(#t #t)"},
+		{program: "(list (string->number \"1/3\") (string->number \"#x10\") (string->number \"x\"))", want: "(1/3 16 #f)"},
+		{program: "(number->string 7/2)", want: `"7/2"`},
+		{program: "(equal? (/ 2 4) 1/2)", want: "#t"},
+		{program: "(/ 1 0)", wantErr: "division by zero"},
+		{program: "(+ 1 1/0)", wantErr: "1:6: division by zero"},
+		{program: "(string->number \"1/0\")", want: "#f"},
+		{program: "(expt 2 100000000000)", wantErr: "exponent 100000000000 too large for exact 2"},
+		{program: "(expt 1/2 -100000000000)", wantErr: "too large"},
+		{program: "(list (expt 1 100000000000) (expt -1 100000000001) (expt 0 100000000000))", want: "(1 -1 0)"},
+		{program: "(expt 2.0 100000000000)", want: "+Inf"},
+		{program: "(/ 1/2 0)", wantErr: "division by zero"},
+		{program: "(exact? 'a)", wantErr: "expected number as argument to exact?"},
+		{program: "(inexact->exact (/ 1.0 0))", wantErr: "no exact representation of +Inf"},
+	}
+	runEvalTests(t, cases)
+}
+
+func TestParseNumber(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    *object
+		wantErr error
+	}{
+		{s: "42", want: newObject(42)},
+		{s: "-7", want: newObject(-7)},
+		{s: "4/2", want: newObject(2)},
+		{s: "1/3", want: newObject(big.NewRat(1, 3))},
+		{s: "#x-1f", want: newObject(-31)},
+		{s: "1e3", want: newObject(1000.0)},
+		{s: "#q1", want: nil},
+		{s: "#b12", want: nil},
+		{s: "1/0", wantErr: errDivideByZero},
+		{s: "-3/00", wantErr: errDivideByZero},
+		{s: "a/b", want: nil},
+		{s: "+", want: nil},
+		{s: "/", want: nil},
+	}
+	for _, tt := range cases {
+		got, err := parseNumber(tt.s)
+		if err != tt.wantErr {
+			t.Errorf("%s: got err %v, want err %v", tt.s, err, tt.wantErr)
+		}
+		if !equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -2,37 +2,75 @@ package golisp
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 )
 
 type typ string
 
 const (
-	TYPE_INT     typ = "int"
-	TYPE_FLOAT   typ = "float"
-	TYPE_SYMBOL  typ = "symbol"
-	TYPE_LIST    typ = "list"
-	TYPE_FN      typ = "fn"
-	TYPE_BUILTIN typ = "builtin"
-	TYPE_LAMBDA  typ = "lambda"
+	TYPE_BOOL     typ = "bool"
+	TYPE_INT      typ = "int"
+	TYPE_BIGINT   typ = "bigint"
+	TYPE_RATIONAL typ = "rational"
+	TYPE_FLOAT    typ = "float"
+	TYPE_SYMBOL   typ = "symbol"
+	TYPE_STRING   typ = "string"
+	TYPE_LIST     typ = "list"
+	TYPE_PAIR     typ = "pair"
+	TYPE_FN       typ = "fn"
+	TYPE_BUILTIN  typ = "builtin"
+	TYPE_LAMBDA   typ = "lambda"
+	TYPE_MACRO    typ = "macro"
+	TYPE_ERROR    typ = "error"
 )
 
 var builtins = []string{
+	"and",
+	"begin",
+	"case",
+	"cond",
 	"define",
+	"define-syntax",
+	"defmacro",
+	"guard",
 	"if",
 	"lambda",
+	"let",
+	"let*",
+	"let-syntax",
+	"letrec",
+	"letrec*",
+	"letrec-syntax",
+	"or",
+	"quasiquote",
 	"quote",
 	"set!",
+	"syntax-rules",
+	"unless",
+	"unquote",
+	"unquote-splicing",
+	"when",
 }
 
 type object struct {
 	t      typ
+	b      bool
 	i      int64
+	big    *big.Int
+	rat    *big.Rat
 	f      float64
 	s      string
 	l      []*object
+	car    *object
+	cdr    *object
 	fn     func(...*object) (*object, error)
 	lambda *lambda
+	macro  *macro
+	err    *Error
+
+	// span is where the object was read from, if it was read from source.
+	span *Span
 }
 
 func isBuiltin(s string) bool {
@@ -44,14 +82,15 @@ func isBuiltin(s string) bool {
 	return false
 }
 
+// isKeyword returns true if s is a keyword, which evaluates to itself.
+func isKeyword(s string) bool {
+	return len(s) > 1 && s[0] == ':'
+}
+
 func newObject(v interface{}) *object {
 	switch v.(type) {
 	case bool:
-		if v.(bool) {
-			return &object{t: TYPE_INT, i: 1}
-		} else {
-			return &object{t: TYPE_INT, i: 0}
-		}
+		return &object{t: TYPE_BOOL, b: v.(bool)}
 	case float64:
 		return &object{t: TYPE_FLOAT, f: v.(float64)}
 	case float32:
@@ -62,6 +101,10 @@ func newObject(v interface{}) *object {
 		return &object{t: TYPE_INT, i: int64(v.(int32))}
 	case int:
 		return &object{t: TYPE_INT, i: int64(v.(int))}
+	case *big.Int:
+		return newInt(v.(*big.Int))
+	case *big.Rat:
+		return newRat(v.(*big.Rat))
 	case string:
 		if isBuiltin(v.(string)) {
 			return &object{t: TYPE_BUILTIN, s: v.(string)}
@@ -73,11 +116,21 @@ func newObject(v interface{}) *object {
 		return &object{t: TYPE_FN, fn: v.(func(...*object) (*object, error))}
 	case *lambda:
 		return &object{t: TYPE_LAMBDA, lambda: v.(*lambda)}
+	case *macro:
+		return &object{t: TYPE_MACRO, macro: v.(*macro)}
+	case *Error:
+		return &object{t: TYPE_ERROR, err: v.(*Error)}
 	default:
 		return nil
 	}
 }
 
+// newString returns a string object. Go strings passed to newObject become
+// symbols.
+func newString(s string) *object {
+	return &object{t: TYPE_STRING, s: s}
+}
+
 func (o *object) toFloat() (float64, error) {
 	if o == nil {
 		return 0.0, fmt.Errorf("cannot convert nil to float")
@@ -87,6 +140,12 @@ func (o *object) toFloat() (float64, error) {
 		return o.f, nil
 	case TYPE_INT:
 		return float64(o.i), nil
+	case TYPE_BIGINT:
+		f, _ := new(big.Float).SetInt(o.big).Float64()
+		return f, nil
+	case TYPE_RATIONAL:
+		f, _ := o.rat.Float64()
+		return f, nil
 	default:
 		return 0.0, fmt.Errorf("cannot convert %q to float", o.t)
 	}
@@ -97,31 +156,64 @@ func (o *object) String() string {
 		return ""
 	}
 	switch o.t {
+	case TYPE_BOOL:
+		if o.b {
+			return "#t"
+		}
+		return "#f"
 	case TYPE_INT:
 		return fmt.Sprintf("%d", o.i)
+	case TYPE_BIGINT:
+		return o.big.String()
+	case TYPE_RATIONAL:
+		return o.rat.RatString()
 	case TYPE_FLOAT:
-		return fmt.Sprintf("%f", o.f)
+		return formatFloat(o.f)
 	case TYPE_SYMBOL, TYPE_BUILTIN:
 		return fmt.Sprintf("%s", o.s)
+	case TYPE_STRING:
+		return quote(o.s)
 	case TYPE_LIST:
 		ss := []string{}
 		for _, o := range o.l {
-			ss = append(ss, o.String())
+			ss = append(ss, o.elemString())
 		}
 		return fmt.Sprintf("(%s)", strings.Join(ss, " "))
+	case TYPE_PAIR:
+		return pairString(o)
+	case TYPE_ERROR:
+		return fmt.Sprintf("#<error %s>", o.err.Err)
 	default:
 		return ""
 	}
 }
 
+// elemString returns the printed representation of o as an element of a list.
+// No value prints as #<void> so that a list holding it is not mistaken for
+// the empty list.
+func (o *object) elemString() string {
+	if o == nil {
+		return "#<void>"
+	}
+	return o.String()
+}
+
+// isTruthy reports whether o is true as a test. #f, nil, zero and the empty
+// list are false.
 func (o *object) isTruthy() bool {
 	if o == nil {
 		return false
 	}
 
 	switch o.t {
+	case TYPE_BOOL:
+		return o.b
 	case TYPE_INT:
 		return o.i != 0
+	case TYPE_BIGINT:
+		return o.big.Sign() != 0
+	case TYPE_RATIONAL:
+		return o.rat.Sign() != 0
 	case TYPE_FLOAT:
 		return o.f != 0.0
 	case TYPE_LIST:
@@ -129,3 +221,86 @@ func (o *object) isTruthy() bool {
 	}
 	return true
 }
+
+// eq reports whether a and b are the same object. Symbols and booleans with
+// the same value, and empty lists, are always the same.
+func eq(a, b *object) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.t != b.t {
+		return false
+	}
+	switch a.t {
+	case TYPE_SYMBOL, TYPE_BUILTIN:
+		return a.s == b.s
+	case TYPE_BOOL:
+		return a.b == b.b
+	case TYPE_LIST:
+		return isEmptyList(a) && isEmptyList(b)
+	}
+	return false
+}
+
+// equal reports whether a and b are structurally equal, regardless of where
+// they were read from.
+func equal(a, b *object) bool {
+	return equalPairs(a, b, map[[2]*object]bool{})
+}
+
+// equalPairs reports whether a and b are structurally equal, given that the
+// pairs in seen are being compared. Comparing a pair again means following a
+// cycle, which cannot show a difference, so it is taken to be equal.
+func equalPairs(a, b *object, seen map[[2]*object]bool) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	if a.t != b.t {
+		// A form and the data it was read as are equal.
+		if (a.t == TYPE_LIST && b.t == TYPE_PAIR) || (a.t == TYPE_PAIR && b.t == TYPE_LIST) {
+			return equalPairs(toData(a), toData(b), seen)
+		}
+		return false
+	}
+	switch a.t {
+	case TYPE_BOOL:
+		return a.b == b.b
+	case TYPE_INT:
+		return a.i == b.i
+	case TYPE_BIGINT:
+		return a.big.Cmp(b.big) == 0
+	case TYPE_RATIONAL:
+		return a.rat.Cmp(b.rat) == 0
+	case TYPE_FLOAT:
+		return a.f == b.f
+	case TYPE_SYMBOL, TYPE_BUILTIN, TYPE_STRING:
+		return a.s == b.s
+	case TYPE_LIST:
+		if len(a.l) != len(b.l) {
+			return false
+		}
+		for i := range a.l {
+			if !equalPairs(a.l[i], b.l[i], seen) {
+				return false
+			}
+		}
+		return true
+	case TYPE_PAIR:
+		k := [2]*object{a, b}
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+		return equalPairs(a.car, b.car, seen) && equalPairs(a.cdr, b.cdr, seen)
+	case TYPE_LAMBDA:
+		return a.lambda == b.lambda
+	case TYPE_MACRO:
+		return a.macro == b.macro
+	case TYPE_ERROR:
+		return a.err == b.err
+	}
+	return false
+}
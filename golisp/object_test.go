@@ -14,11 +14,11 @@ func TestNewObject(t *testing.T) {
 	}{
 		{
 			v:    false,
-			want: &object{t: TYPE_INT, i: 0},
+			want: &object{t: TYPE_BOOL, b: false},
 		},
 		{
 			v:    true,
-			want: &object{t: TYPE_INT, i: 1},
+			want: &object{t: TYPE_BOOL, b: true},
 		},
 		{
 			v:    42,
@@ -112,7 +112,7 @@ func TestString(t *testing.T) {
 		},
 		{
 			o:    newObject(42.0),
-			want: fmt.Sprintf("%f", 42.0),
+			want: "42.0",
 		},
 		{
 			o:    newObject("if"),
@@ -122,6 +122,10 @@ func TestString(t *testing.T) {
 			o:    newObject("foo"),
 			want: "foo",
 		},
+		{
+			o:    newString("a \"b\"\n\x01"),
+			want: `"a \"b\"\n\u0001"`,
+		},
 		{
 			o:    newObject([]*object{newObject(0), newObject(1), newObject(2)}),
 			want: "(0 1 2)",
@@ -156,6 +160,8 @@ func TestIsTruthy(t *testing.T) {
 		{newObject([]*object{newObject(42)}), true},
 		{newObject([]*object{}), false},
 		{newObject("foo"), true},
+		{newObject(true), true},
+		{newObject(false), false},
 	}
 
 	for _, tt := range cases {
@@ -164,3 +170,36 @@ func TestIsTruthy(t *testing.T) {
 		}
 	}
 }
+
+func TestEqual(t *testing.T) {
+	read := func(program string) *object {
+		forms, err := readForms("", program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return forms[0]
+	}
+
+	l := newObject(&lambda{})
+	cases := []struct {
+		a, b *object
+		want bool
+	}{
+		{nil, nil, true},
+		{newObject(1), nil, false},
+		{newObject(1), read("1"), true},
+		{newObject(1), newObject(1.0), false},
+		{newObject("a"), read("a"), true},
+		{newString("a"), newObject("a"), false},
+		{read("(a (b \"c\") 4.5)"), read(" (a  (b \"c\")\n4.5)"), true},
+		{read("(a b)"), read("(a b c)"), false},
+		{l, l, true},
+		{l, newObject(&lambda{}), false},
+	}
+
+	for _, tt := range cases {
+		if got := equal(tt.a, tt.b); got != tt.want {
+			t.Errorf("equal(%s, %s): got %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Lists made at runtime, by quote, list, cons and the like, are chains of
+// pairs ending in the empty list. Forms read from source are TYPE_LIST, backed
+// by a slice, so that eval can index into them; quote converts them to pairs
+// with toData and defmacro converts its expansion back with toSyntax. In both
+// a . before the last element makes the list improper.
+
+// cons returns a new pair of car and cdr.
+func cons(car, cdr *object) *object {
+	return &object{t: TYPE_PAIR, car: car, cdr: cdr}
+}
+
+// empty is the empty list, which ends every proper list made at runtime. It is
+// distinct from nil, which is the absence of a value.
+var empty = &object{t: TYPE_LIST, l: []*object{}}
+
+// emptyList returns the empty list.
+func emptyList() *object {
+	return empty
+}
+
+// isEmptyList reports whether o is the empty list, or the empty form () that
+// it is read as.
+func isEmptyList(o *object) bool {
+	return o != nil && o.t == TYPE_LIST && len(o.l) == 0
+}
+
+// list returns a proper list of os.
+func list(os ...*object) *object {
+	return listWithTail(os, emptyList())
+}
+
+// listWithTail returns a list of os whose last pair has tail as its cdr.
+func listWithTail(os []*object, tail *object) *object {
+	l := tail
+	for i := len(os) - 1; i >= 0; i-- {
+		l = cons(os[i], l)
+	}
+	return l
+}
+
+// toSlice returns the elements of the proper list o.
+func toSlice(o *object) ([]*object, error) {
+	if o != nil && o.t == TYPE_LIST {
+		return o.l, nil
+	}
+	var l []*object
+	// slow follows p at half its speed, so p catches up with it only if the
+	// list is circular.
+	slow := o
+	for p := o; !isEmptyList(p); p = p.cdr {
+		if p == nil || p.t != TYPE_PAIR {
+			return nil, fmt.Errorf("expected proper list, got %s", o)
+		}
+		l = append(l, p.car)
+		if len(l)%2 == 0 {
+			slow = slow.cdr
+		}
+		if p.cdr == slow {
+			return nil, errors.New("expected proper list, got circular list")
+		}
+	}
+	return l, nil
+}
+
+// isList reports whether o is a proper list.
+func isList(o *object) bool {
+	if o == nil || (o.t != TYPE_LIST && o.t != TYPE_PAIR) {
+		return false
+	}
+	_, err := toSlice(o)
+	return err == nil
+}
+
+// isDot reports whether x is the . of an improper list.
+func isDot(x *object) bool {
+	return x != nil && x.t == TYPE_SYMBOL && x.s == "."
+}
+
+// toData returns the form o with its lists converted to pairs.
+func toData(o *object) *object {
+	if isEmptyList(o) {
+		return emptyList()
+	}
+	if o == nil || o.t != TYPE_LIST {
+		return o
+	}
+	l, tail := o.l, emptyList()
+	if n := len(l); n > 2 && isDot(l[n-2]) {
+		l, tail = l[:n-2], toData(l[n-1])
+	}
+	data := make([]*object, len(l))
+	for i, e := range l {
+		data[i] = toData(e)
+	}
+	return listWithTail(data, tail)
+}
+
+// toSyntax returns the data o with its pairs converted to forms. No value has
+// no syntax, so it is an error for o or any element of it to be nil.
+func toSyntax(o *object) (*object, error) {
+	if o == nil {
+		return nil, errors.New("expected form, got <nothing>")
+	}
+	if o.t != TYPE_PAIR {
+		return o, nil
+	}
+	l := []*object{}
+	p := o
+	for ; p != nil && p.t == TYPE_PAIR; p = p.cdr {
+		x, err := toSyntax(p.car)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, x)
+	}
+	if !isEmptyList(p) {
+		x, err := toSyntax(p)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, newObject("."), x)
+	}
+	return newObject(l), nil
+}
+
+// pairString returns the printed representation of the pair o. A pair that
+// refers back to one being printed is printed as ... to stop at the cycle.
+func pairString(o *object) string {
+	return writePairs(o, map[*object]bool{})
+}
+
+// writePairs returns the printed representation of the pair o, of which the
+// pairs in open are being printed.
+func writePairs(o *object, open map[*object]bool) string {
+	ss := []string{}
+	var chain []*object
+	p := o
+	for ; p != nil && p.t == TYPE_PAIR && !open[p]; p = p.cdr {
+		open[p] = true
+		chain = append(chain, p)
+		switch {
+		case p.car == nil || p.car.t != TYPE_PAIR:
+			ss = append(ss, p.car.elemString())
+		case open[p.car]:
+			ss = append(ss, "...")
+		default:
+			ss = append(ss, writePairs(p.car, open))
+		}
+	}
+	switch {
+	case p != nil && p.t == TYPE_PAIR:
+		ss = append(ss, "...")
+	case !isEmptyList(p):
+		ss = append(ss, ".", p.elemString())
+	}
+	for _, c := range chain {
+		delete(open, c)
+	}
+	return fmt.Sprintf("(%s)", strings.Join(ss, " "))
+}
@@ -0,0 +1,120 @@
+package golisp
+
+import "testing"
+
+func TestPairs(t *testing.T) {
+	cases := []evalTest{
+		{program: "(cons 1 2)", want: "(1 . 2)"},
+		{program: "(cons 1 '(2 3))", want: "(1 2 3)"},
+		{program: "(cons 1 '())", want: "(1)"},
+		{program: "'(a . b)", want: "(a . b)"},
+		{program: "'(1 2 . 3)", want: "(1 2 . 3)"},
+		{program: "'(1 . (2 3))", want: "(1 2 3)"},
+		{program: "(cdr '(a . b))", want: "b"},
+		{program: "(cdr (cdr '(1 2 . 3)))", want: "3"},
+		{program: "(list (pair? '(1)) (pair? '()) (pair? 1))", want: "(#t #f #f)"},
+		{program: "(list (list? '(1 2)) (list? '(1 . 2)) (list? '()))", want: "(#t #f #t)"},
+		{program: "(equal? (cons 1 (cons 2 '())) '(1 2))", want: "#t"},
+		{program: "(define p (list 1 2)) (set-car! p 3) p", want: "(3 2)"},
+		{program: "(define p (list 1 2)) (set-cdr! (cdr p) 3) p", want: "(1 2 . 3)"},
+		{program: "(define l (list 1 2)) (define m (cons 0 l)) (set-car! l 9) m", want: "(0 9 2)"},
+		{program: "`(1 ,@(list 2 3) . 4)", want: "(1 2 3 . 4)"},
+		{program: "(defmacro swap (a . b) (cons 'list (cons (car b) (list a)))) (swap 1 2)", want: "(2 1)"},
+		{program: "(define l (list 1)) (set-cdr! l l) l", want: "(1 ...)"},
+		{program: "(define l (list 1 2 3)) (set-cdr! (cdr (cdr l)) (cdr l)) (list (list? l) l)", want: "(#f (1 2 3 ...))"},
+		{program: "(define l (list 1 2)) (set-car! l l) l", want: "(... 2)"},
+		{program: "(define a (list 1)) (list a a)", want: "((1) (1))"},
+		{program: "(define p (list 1)) (set-cdr! p p) (equal? p p)", want: "#t"},
+		{program: "(define p (list 1)) (set-cdr! p p) (define q (list 1 1)) (set-cdr! (cdr q) q) (equal? p q)", want: "#t"},
+		{program: "(define p (list 1)) (set-cdr! p p) (define q (list 1 2)) (set-cdr! (cdr q) q) (equal? p q)", want: "#f"},
+		{program: "(define l (list 1)) (set-cdr! l l) (length l)", wantErr: "expected list as argument to len"},
+		{program: "(length '(1 . 2))", wantErr: "expected list as argument to len"},
+		{program: "(set-car! '() 1)", wantErr: "expected pair as first argument to set-car!"},
+		{program: "(car '())", wantErr: "expected pair as argument to car"},
+		{program: "'(. a)", wantErr: "unexpected '.'"},
+		{program: "'(a . b c)", wantErr: "unexpected '.'"},
+	}
+	runEvalTests(t, cases)
+}
+
+func TestEmptyList(t *testing.T) {
+	cases := []evalTest{
+		{program: "(list (null? (list)) (null? '()) (null? ()) (null? (cdr '(1))))", want: "(#t #t #t #t)"},
+		{program: "(list (null? '(1)) (null? 0) (null? (define x 1)))", want: "(#f #f #f)"},
+		{program: "(list (pair? '()) (list? '()))", want: "(#f #t)"},
+		{program: "(list (eq? '() (list)) (eq? 'a 'a) (eq? '(1) '(1)))", want: "(#t #t #f)"},
+		{program: "(define l '(1)) (eq? l l)", want: "#t"},
+		{program: "(cdr '(1))", want: "()"},
+		{program: "(list (if #f #f))", want: "(#<void>)"},
+		{program: "(cons 1 (if #f #f))", want: "(1 . #<void>)"},
+		{program: "(list (procedure? (if #f #f)) (symbol? (if #f #f)) (number? (if #f #f)))", want: "(#f #f #f)"},
+		{program: "(car '())", wantErr: "expected pair as argument to car"},
+		{program: "(cdr (list))", wantErr: "expected pair as argument to cdr"},
+		{program: "(null?)", wantErr: "expected one argument to null?"},
+		{program: "(number?)", wantErr: "expected one argument to number?"},
+	}
+	runEvalTests(t, cases)
+
+	v, err := New().Eval("(define x 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.IsNil() || v.IsNull() {
+		t.Errorf("define: got IsNil %t IsNull %t, want no value", v.IsNil(), v.IsNull())
+	}
+	if v, err = New().Eval("'()"); err != nil {
+		t.Fatal(err)
+	}
+	if v.IsNil() || !v.IsNull() {
+		t.Errorf("'(): got IsNil %t IsNull %t, want the empty list", v.IsNil(), v.IsNull())
+	}
+}
+
+func TestToSlice(t *testing.T) {
+	l, err := toSlice(list(newObject(1), newObject(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 || l[0].i != 1 || l[1].i != 2 {
+		t.Errorf("got %v, want [1 2]", l)
+	}
+	if _, err := toSlice(cons(newObject(1), newObject(2))); err == nil {
+		t.Error("got nil error for improper list")
+	}
+	for n := 1; n <= 4; n++ {
+		c := list(newObject(1), newObject(2), newObject(3), newObject(4))
+		last := c
+		for last.cdr.t == TYPE_PAIR {
+			last = last.cdr
+		}
+		loop := c
+		for i := 1; i < n; i++ {
+			loop = loop.cdr
+		}
+		last.cdr = loop
+		if _, err := toSlice(c); err == nil || err.Error() != "expected proper list, got circular list" {
+			t.Errorf("cycle to element %d: got err %v, want circular list", n, err)
+		}
+	}
+}
+
+func TestSyntaxRoundTrip(t *testing.T) {
+	for _, program := range []string{"(a b c)", "(a (b . c) . d)", "()"} {
+		forms, err := readForms("", program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		form := forms[0]
+		data := toData(form)
+		if got := data.String(); got != program {
+			t.Errorf("%s: data printed as %s", program, got)
+		}
+		syntax, err := toSyntax(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := syntax.String(); got != program {
+			t.Errorf("%s: syntax printed as %s", program, got)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package golisp
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is the range of source text an expression was read from. Lines and
+// columns count from one and columns count runes.
+type Span struct {
+	File            string
+	Line, Col       int
+	EndLine, EndCol int
+
+	// src is the source text the span refers to.
+	src *string
+}
+
+// String returns the start of the span as file:line:col.
+func (s Span) String() string {
+	if s.Line == 0 {
+		return "?"
+	}
+	if s.File == "" {
+		return fmt.Sprintf("%d:%d", s.Line, s.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Line, s.Col)
+}
+
+// line returns the source text of the line the span starts on.
+func (s Span) line() (string, bool) {
+	if s.src == nil || s.Line == 0 {
+		return "", false
+	}
+	lines := strings.Split(*s.src, "\n")
+	if s.Line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[s.Line-1], "\r"), true
+}
+
+// token is a lexical token and where it was read from.
+type token struct {
+	s    string
+	span Span
+}
+
+// scanner splits source text into tokens, skipping whitespace and comments.
+type scanner struct {
+	file      string
+	src       string
+	off       int
+	line, col int
+}
+
+func newScanner(file, src string) *scanner {
+	return &scanner{file: file, src: src, line: 1, col: 1}
+}
+
+// peek returns the rune at the current offset, or utf8.RuneError at the end
+// of the source.
+func (s *scanner) peek() rune {
+	if s.off >= len(s.src) {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(s.src[s.off:])
+	return r
+}
+
+// next advances past the current rune.
+func (s *scanner) next() rune {
+	r, n := utf8.DecodeRuneInString(s.src[s.off:])
+	s.off += n
+	if r == '\n' {
+		s.line++
+		s.col = 1
+	} else {
+		s.col++
+	}
+	return r
+}
+
+func (s *scanner) done() bool {
+	return s.off >= len(s.src)
+}
+
+// span returns a span from start to the current position.
+func (s *scanner) span(start Span) Span {
+	start.EndLine, start.EndCol = s.line, s.col
+	return start
+}
+
+func (s *scanner) pos() Span {
+	return Span{File: s.file, Line: s.line, Col: s.col, src: &s.src}
+}
+
+func isDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' || r == ';'
+}
+
+// skip advances past whitespace, line comments and nested block comments.
+func (s *scanner) skip() error {
+	for !s.done() {
+		switch r := s.peek(); {
+		case unicode.IsSpace(r):
+			s.next()
+		case r == ';':
+			for !s.done() && s.peek() != '\n' {
+				s.next()
+			}
+		case strings.HasPrefix(s.src[s.off:], "#|"):
+			start := s.pos()
+			s.next()
+			s.next()
+			depth := 1
+			for depth > 0 {
+				switch {
+				case s.done():
+					return syntaxError(s.span(start), fmt.Errorf("%w, unterminated block comment", errEOF))
+				case strings.HasPrefix(s.src[s.off:], "#|"):
+					s.next()
+					s.next()
+					depth++
+				case strings.HasPrefix(s.src[s.off:], "|#"):
+					s.next()
+					s.next()
+					depth--
+				default:
+					s.next()
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// scan returns the next token. It returns false when the source is exhausted.
+func (s *scanner) scan() (token, bool, error) {
+	if err := s.skip(); err != nil {
+		return token{}, false, err
+	}
+	if s.done() {
+		return token{}, false, nil
+	}
+
+	start := s.pos()
+	from := s.off
+	switch r := s.next(); r {
+	case '(', ')', '\'', '`':
+	case ',':
+		if s.peek() == '@' {
+			s.next()
+		}
+	case '"':
+		for {
+			if s.done() {
+				return token{}, false, syntaxError(s.span(start), fmt.Errorf("%w, unterminated string", errEOF))
+			}
+			r := s.next()
+			if r == '\\' && !s.done() {
+				s.next()
+			} else if r == '"' {
+				break
+			}
+		}
+	default:
+		for !s.done() && !isDelimiter(s.peek()) {
+			s.next()
+		}
+	}
+	return token{s.src[from:s.off], s.span(start)}, true, nil
+}
+
+// tokenize returns all of the tokens in program, which was read from file.
+func tokenize(file, program string) ([]token, error) {
+	tokens := []token{}
+	s := newScanner(file, program)
+	for {
+		t, ok, err := s.scan()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return tokens, nil
+		}
+		tokens = append(tokens, t)
+	}
+}
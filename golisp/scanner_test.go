@@ -0,0 +1,93 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		program string
+		want    []string
+		wantErr error
+	}{
+		{
+			program: "",
+			want:    []string{},
+		},
+		{
+			program: "(begin (* pi (* r r)))",
+			want:    []string{"(", "begin", "(", "*", "pi", "(", "*", "r", "r", ")", ")", ")"},
+		},
+		{
+			program: "(a\tb\r\nc d e)",
+			want:    []string{"(", "a", "b", "c", "d", "e", ")"},
+		},
+		{
+			program: `(list "a (b)" "c\"d";e`,
+			want:    []string{"(", "list", `"a (b)"`, `"c\"d"`},
+		},
+		{
+			program: "(a ; comment (\n b) ; trailing",
+			want:    []string{"(", "a", "b", ")"},
+		},
+		{
+			program: "(a #| block #| nested |# ( |# b)",
+			want:    []string{"(", "a", "b", ")"},
+		},
+		{
+			program: "'a `(b ,c ,@d)",
+			want:    []string{"'", "a", "`", "(", "b", ",", "c", ",@", "d", ")"},
+		},
+		{
+			program: "(a \"bc",
+			wantErr: errors.New("1:4: unexpected EOF, unterminated string"),
+		},
+		{
+			program: "a\n #| b",
+			wantErr: errors.New("2:2: unexpected EOF, unterminated block comment"),
+		},
+	}
+
+	for _, tt := range cases {
+		tokens, err := tokenize("", tt.program)
+		if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
+			t.Errorf("%q: got err %q, want err %q", tt.program, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		got := []string{}
+		for _, tok := range tokens {
+			got = append(got, tok.s)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %#v, want %#v", tt.program, got, tt.want)
+		}
+	}
+}
+
+func TestTokenSpans(t *testing.T) {
+	tokens, err := tokenize("f.lisp", "(foo\n  \"a\nb\" ;x\n\tbär)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []token{
+		{"(", Span{File: "f.lisp", Line: 1, Col: 1, EndLine: 1, EndCol: 2}},
+		{"foo", Span{File: "f.lisp", Line: 1, Col: 2, EndLine: 1, EndCol: 5}},
+		{"\"a\nb\"", Span{File: "f.lisp", Line: 2, Col: 3, EndLine: 3, EndCol: 3}},
+		{"bär", Span{File: "f.lisp", Line: 4, Col: 2, EndLine: 4, EndCol: 5}},
+		{")", Span{File: "f.lisp", Line: 4, Col: 5, EndLine: 4, EndCol: 6}},
+	}
+	for i := range tokens {
+		tokens[i].span.src = nil
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got %+v, want %+v", tokens, want)
+	}
+	if got := want[1].span.String(); got != "f.lisp:1:2" {
+		t.Errorf("got %q, want f.lisp:1:2", got)
+	}
+}
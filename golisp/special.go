@@ -0,0 +1,508 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// evalBody evaluates all but the last of the forms of a body in e, and returns
+// the last to be evaluated in tail position.
+func evalBody(e *env, name string, forms []*object) (*object, error) {
+	if len(forms) == 0 {
+		return nil, fmt.Errorf("expected at least one body expression in %s", name)
+	}
+	for _, f := range forms[:len(forms)-1] {
+		if _, err := eval(e, f); err != nil {
+			return nil, err
+		}
+	}
+	return forms[len(forms)-1], nil
+}
+
+// sequence returns a single expression that evaluates the forms of a body in
+// order.
+func sequence(forms []*object) *object {
+	if len(forms) == 1 {
+		return forms[0]
+	}
+	return newObject(append([]*object{newObject("begin")}, forms...))
+}
+
+// binding is a name and the expression giving its value.
+type binding struct {
+	name string
+	exp  *object
+}
+
+// parseBindings checks that b is a list of (name expression) pairs.
+func parseBindings(name string, b *object) ([]binding, error) {
+	if b.t != TYPE_LIST {
+		return nil, fmt.Errorf("expected list of bindings in %s, got %s", name, b)
+	}
+	bs := make([]binding, len(b.l))
+	for i, p := range b.l {
+		if p.t != TYPE_LIST || len(p.l) != 2 || p.l[0].t != TYPE_SYMBOL {
+			return nil, fmt.Errorf("expected (name value) binding in %s, got %s", name, p)
+		}
+		bs[i] = binding{p.l[0].s, p.l[1]}
+	}
+	return bs, nil
+}
+
+// newScope returns an empty scope enclosed by e.
+func newScope(e *env) *env {
+	return &env{outer: e, m: map[string]*object{}}
+}
+
+// evalLet evaluates the bindings of a let, or a named let, form x and returns
+// the scope and expression in which to evaluate its body.
+//
+//	(let ((name value) ...) body ...)
+//	(let loop ((name value) ...) body ...)
+func evalLet(e *env, x *object) (*env, *object, error) {
+	if len(x.l) > 1 && x.l[1].t == TYPE_SYMBOL {
+		return evalNamedLet(e, x)
+	}
+	if len(x.l) < 3 {
+		return nil, nil, fmt.Errorf("expected bindings and body in let")
+	}
+	bs, err := parseBindings("let", x.l[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inner := newScope(e)
+	for _, b := range bs {
+		v, err := eval(e, b.exp)
+		if err != nil {
+			return nil, nil, err
+		}
+		inner.define(b.name, v)
+	}
+	body, err := evalBody(inner, "let", x.l[2:])
+	return inner, body, err
+}
+
+// evalNamedLet binds the name of a named let to a procedure taking the bound
+// names as parameters and calls it with their values.
+func evalNamedLet(e *env, x *object) (*env, *object, error) {
+	if len(x.l) < 4 {
+		return nil, nil, fmt.Errorf("expected name, bindings and body in let")
+	}
+	name := x.l[1].s
+	bs, err := parseBindings("let", x.l[2])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := make([]*object, len(bs))
+	args := make([]*object, len(bs))
+	for i, b := range bs {
+		params[i] = newObject(b.name)
+		if args[i], err = eval(e, b.exp); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The procedure is bound in its own scope so that it can call itself.
+	outer := newScope(e)
+	l, err := newLambda(newObject(params), sequence(x.l[3:]), outer)
+	if err != nil {
+		return nil, nil, err
+	}
+	l.name = name
+	outer.define(name, newObject(l))
+	log.Printf("named let %q\n", name)
+
+	inner, err := l.bind(args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inner, l.body, nil
+}
+
+// evalLetStar evaluates the bindings of a let* form x in order, each in the
+// scope of the ones before, and returns the scope and expression in which to
+// evaluate its body.
+//
+//	(let* ((name value) ...) body ...)
+func evalLetStar(e *env, x *object) (*env, *object, error) {
+	if len(x.l) < 3 {
+		return nil, nil, fmt.Errorf("expected bindings and body in let*")
+	}
+	bs, err := parseBindings("let*", x.l[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inner := newScope(e)
+	for _, b := range bs {
+		v, err := eval(inner, b.exp)
+		if err != nil {
+			return nil, nil, err
+		}
+		inner = newScope(inner)
+		inner.define(b.name, v)
+	}
+	body, err := evalBody(inner, "let*", x.l[2:])
+	return inner, body, err
+}
+
+// evalLetrec evaluates the bindings of a letrec or letrec* form x in a scope
+// in which all of the names are bound, so that they may refer to each other,
+// and returns the scope and expression in which to evaluate its body. The
+// values of letrec are assigned after all are evaluated and those of letrec*
+// as each is evaluated.
+//
+//	(letrec ((name value) ...) body ...)
+func evalLetrec(e *env, x *object) (*env, *object, error) {
+	name := x.l[0].s
+	if len(x.l) < 3 {
+		return nil, nil, fmt.Errorf("expected bindings and body in %s", name)
+	}
+	bs, err := parseBindings(name, x.l[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inner := newScope(e)
+	for _, b := range bs {
+		inner.define(b.name, nil)
+	}
+	vs := make([]*object, len(bs))
+	for i, b := range bs {
+		if vs[i], err = eval(inner, b.exp); err != nil {
+			return nil, nil, err
+		}
+		if name == "letrec*" {
+			inner.define(b.name, vs[i])
+		}
+	}
+	for i, b := range bs {
+		inner.define(b.name, vs[i])
+	}
+	body, err := evalBody(inner, name, x.l[2:])
+	return inner, body, err
+}
+
+// checkForm returns an error unless the special form x has between min and
+// max arguments. A negative max means there is no upper limit.
+func checkForm(x *object, min, max int) error {
+	n, name := len(x.l)-1, x.l[0].s
+	switch {
+	case min == max && n != min:
+		return newError(ArityError, "expected %s to %s", numArgs(min), name)
+	case n < min:
+		return newError(ArityError, "expected at least %s to %s", numArgs(min), name)
+	case max >= 0 && n > max:
+		return newError(ArityError, "expected at most %s to %s", numArgs(max), name)
+	}
+	return nil
+}
+
+// isElse returns true if x is the else keyword of a cond or case clause.
+func isElse(x *object) bool {
+	return x.t == TYPE_SYMBOL && x.s == "else"
+}
+
+// isArrow returns true if x is the => keyword of a cond or case clause.
+func isArrow(x *object) bool {
+	return x.t == TYPE_SYMBOL && x.s == "=>"
+}
+
+// quoted returns an expression that evaluates to the already evaluated v.
+func quoted(v *object) *object {
+	return newObject([]*object{newObject("quote"), v})
+}
+
+// applyTo returns an expression that calls the result of evaluating proc with
+// the already evaluated v.
+func applyTo(proc, v *object) *object {
+	return newObject([]*object{proc, quoted(v)})
+}
+
+// evalIf evaluates the test of an if form x and returns the branch to
+// evaluate in tail position. A one-armed if with a false test has no value.
+//
+//	(if test conseq [alt])
+func evalIf(e *env, x *object) (*object, *object, error) {
+	if err := checkForm(x, 2, 3); err != nil {
+		return nil, nil, err
+	}
+	res, err := eval(e, x.l[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("test result: %#v", res)
+	switch {
+	case e.truthy(res):
+		return nil, x.l[2], nil
+	case len(x.l) == 4:
+		return nil, x.l[3], nil
+	}
+	return nil, nil, nil
+}
+
+// evalCond evaluates the tests of the clauses of a cond form x in order until
+// one is true and returns either its value or the expression to evaluate in
+// tail position.
+//
+//	(cond (test body ...) (test => proc) (test) ... (else body ...))
+func evalCond(e *env, x *object) (*object, *object, error) {
+	if err := checkForm(x, 1, -1); err != nil {
+		return nil, nil, err
+	}
+	for i, c := range x.l[1:] {
+		if c.t != TYPE_LIST || len(c.l) == 0 {
+			return nil, nil, fmt.Errorf("expected (test body ...) clause in cond, got %s", c)
+		}
+		if isElse(c.l[0]) {
+			if i != len(x.l)-2 {
+				return nil, nil, errors.New("expected else clause to be last in cond")
+			}
+			tail, err := evalBody(e, "cond", c.l[1:])
+			return nil, tail, err
+		}
+
+		res, err := eval(e, c.l[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if !e.truthy(res) {
+			continue
+		}
+		switch {
+		case len(c.l) == 1:
+			return res, nil, nil
+		case isArrow(c.l[1]):
+			if len(c.l) != 3 {
+				return nil, nil, errors.New("expected one procedure after => in cond")
+			}
+			return nil, applyTo(c.l[2], res), nil
+		}
+		tail, err := evalBody(e, "cond", c.l[1:])
+		return nil, tail, err
+	}
+	return nil, nil, nil
+}
+
+// evalCase evaluates the key of a case form x and returns the expression to
+// evaluate in tail position from the first clause with a datum equal to it.
+//
+//	(case key ((datum ...) body ...) ... (else body ...))
+func evalCase(e *env, x *object) (*object, *object, error) {
+	if err := checkForm(x, 2, -1); err != nil {
+		return nil, nil, err
+	}
+	key, err := eval(e, x.l[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, c := range x.l[2:] {
+		if c.t != TYPE_LIST || len(c.l) < 2 {
+			return nil, nil, fmt.Errorf("expected ((datum ...) body ...) clause in case, got %s", c)
+		}
+		match := isElse(c.l[0])
+		switch {
+		case match:
+			if i != len(x.l)-3 {
+				return nil, nil, errors.New("expected else clause to be last in case")
+			}
+		case c.l[0].t != TYPE_LIST:
+			return nil, nil, fmt.Errorf("expected list of datums in case, got %s", c.l[0])
+		default:
+			for _, d := range c.l[0].l {
+				if equal(key, d) {
+					match = true
+					break
+				}
+			}
+		}
+		if !match {
+			continue
+		}
+		if isArrow(c.l[1]) {
+			if len(c.l) != 3 {
+				return nil, nil, errors.New("expected one procedure after => in case")
+			}
+			return nil, applyTo(c.l[2], key), nil
+		}
+		tail, err := evalBody(e, "case", c.l[1:])
+		return nil, tail, err
+	}
+	return nil, nil, nil
+}
+
+// evalWhen evaluates the test of a when or unless form x and, if it is true
+// or false respectively, returns the last form of the body to evaluate in
+// tail position.
+//
+//	(when test body ...)
+//	(unless test body ...)
+func evalWhen(e *env, x *object) (*object, *object, error) {
+	if err := checkForm(x, 2, -1); err != nil {
+		return nil, nil, err
+	}
+	res, err := eval(e, x.l[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.truthy(res) != (x.l[0].s == "when") {
+		return nil, nil, nil
+	}
+	tail, err := evalBody(e, x.l[0].s, x.l[2:])
+	return nil, tail, err
+}
+
+// evalAnd evaluates the arguments of an and or or form x in order until one
+// is false or true respectively, and returns its value. The last argument is
+// returned to be evaluated in tail position.
+//
+//	(and test ...)
+//	(or test ...)
+func evalAnd(e *env, x *object) (*object, *object, error) {
+	and := x.l[0].s == "and"
+	if len(x.l) == 1 {
+		return newObject(and), nil, nil
+	}
+	for _, t := range x.l[1 : len(x.l)-1] {
+		res, err := eval(e, t)
+		if err != nil {
+			return nil, nil, err
+		}
+		if e.truthy(res) != and {
+			return res, nil, nil
+		}
+	}
+	return nil, x.l[len(x.l)-1], nil
+}
+
+// makeLambda returns a lambda taking params enclosed by e. A body of several
+// forms is evaluated as by begin, and if the first of them is a string it is
+// the lambda's docstring.
+func makeLambda(e *env, params *object, body []*object) (*lambda, error) {
+	doc := ""
+	if len(body) > 1 && body[0].t == TYPE_STRING {
+		doc, body = body[0].s, body[1:]
+	}
+	l, err := newLambda(params, sequence(body), e)
+	if err != nil {
+		return nil, err
+	}
+	l.doc = doc
+	return l, nil
+}
+
+// evalDefine binds a name in e to the value of a define form x. The
+// procedure shorthand is curried if the name is itself a shorthand.
+//
+//	(define name value)
+//	(define (name params ...) body ...)
+//	(define ((name params ...) params ...) body ...)
+func evalDefine(e *env, x *object) error {
+	if err := checkForm(x, 2, -1); err != nil {
+		return err
+	}
+	target, exp := x.l[1], x.l[2]
+	if target.t == TYPE_LIST {
+		// Unwrap each level of shorthand into a lambda around the body.
+		body := x.l[2:]
+		for target.t == TYPE_LIST {
+			if len(target.l) == 0 {
+				return errors.New("expected name in define")
+			}
+			params := newObject(target.l[1:])
+			exp = newObject(append([]*object{newObject("lambda"), params}, body...))
+			target, body = target.l[0], []*object{exp}
+		}
+	} else if len(x.l) != 3 {
+		return newError(ArityError, "expected two arguments to define")
+	}
+	if target.t != TYPE_SYMBOL || isKeyword(target.s) {
+		return fmt.Errorf("expected symbol to define, got %s", target)
+	}
+
+	v, err := eval(e, exp)
+	if err != nil {
+		return err
+	}
+	if v != nil && v.t == TYPE_LAMBDA && v.lambda.name == "" {
+		v.lambda.name = target.s
+	}
+	e.define(target.s, v)
+	return nil
+}
+
+// quasiquote returns the template x as data with the values of the unquoted
+// expressions within it, evaluated in e, in place of them. Unquotes within a
+// nested quasiquote are at a deeper level, and are only evaluated at depth 1.
+//
+//	(quasiquote (a (unquote b) (unquote-splicing c)))
+func quasiquote(e *env, x *object, depth int) (*object, error) {
+	if x == nil || x.t != TYPE_LIST || len(x.l) == 0 {
+		return toData(x), nil
+	}
+	if h := x.l[0]; h.t == TYPE_BUILTIN && len(x.l) == 2 {
+		switch h.s {
+		case "unquote":
+			if depth == 1 {
+				return eval(e, x.l[1])
+			}
+			return quasiquoteLevel(e, x, depth-1)
+		case "quasiquote":
+			return quasiquoteLevel(e, x, depth+1)
+		}
+	}
+
+	elems, tail := x.l, emptyList()
+	if n := len(elems); n > 2 && isDot(elems[n-2]) {
+		var err error
+		if tail, err = quasiquote(e, elems[n-1], depth); err != nil {
+			return nil, err
+		}
+		elems = elems[:n-2]
+	}
+	l := []*object{}
+	for _, u := range elems {
+		if u != nil && u.t == TYPE_LIST && len(u.l) == 2 && u.l[0].t == TYPE_BUILTIN && u.l[0].s == "unquote-splicing" {
+			if depth > 1 {
+				v, err := quasiquoteLevel(e, u, depth-1)
+				if err != nil {
+					return nil, err
+				}
+				l = append(l, v)
+				continue
+			}
+			v, err := eval(e, u.l[1])
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				continue
+			}
+			vs, err := toSlice(v)
+			if err != nil || (v.t != TYPE_LIST && v.t != TYPE_PAIR) {
+				return nil, fmt.Errorf("expected list to unquote-splicing, got %s", v)
+			}
+			l = append(l, vs...)
+			continue
+		}
+		v, err := quasiquote(e, u, depth)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+	}
+	return listWithTail(l, tail), nil
+}
+
+// quasiquoteLevel returns the form x, one of quasiquote, unquote or
+// unquote-splicing, as data with its template quasiquoted at depth.
+func quasiquoteLevel(e *env, x *object, depth int) (*object, error) {
+	v, err := quasiquote(e, x.l[1], depth)
+	if err != nil {
+		return nil, err
+	}
+	return list(x.l[0], v), nil
+}
@@ -0,0 +1,86 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// unquote returns the contents of the string literal token with its escape
+// sequences replaced.
+func unquote(token string) (string, error) {
+	var b strings.Builder
+	s := token[1:]
+	for {
+		i := strings.IndexAny(s, `"\`)
+		if i == -1 || (s[i] == '\\' && i == len(s)-1) {
+			return "", errors.New("unterminated string")
+		}
+		b.WriteString(s[:i])
+		if s[i] == '"' {
+			if i != len(s)-1 {
+				return "", errors.New("unexpected characters after string")
+			}
+			return b.String(), nil
+		}
+		s = s[i+1:]
+
+		c := s[0]
+		s = s[1:]
+		switch c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if len(s) < n {
+				return "", fmt.Errorf("invalid escape \\%c%s", c, strings.TrimSuffix(s, `"`))
+			}
+			r, err := strconv.ParseUint(s[:n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", fmt.Errorf("invalid escape \\%c%s", c, s[:n])
+			}
+			b.WriteRune(rune(r))
+			s = s[n:]
+		default:
+			return "", fmt.Errorf("invalid escape \\%c", c)
+		}
+	}
+}
+
+// quote returns s as a string literal that unquote reverses.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
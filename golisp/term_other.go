@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package golisp
+
+import "errors"
+
+// isTerminal reports whether fd refers to a terminal. Line editing is not
+// supported on this platform so it always reports false.
+func isTerminal(fd int) bool {
+	return false
+}
+
+func rawMode(fd int) (func() error, error) {
+	return nil, errors.New("raw mode is not supported on this platform")
+}
+
+func terminalWidth(fd int) int {
+	return 80
+}
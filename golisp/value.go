@@ -0,0 +1,470 @@
+package golisp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// Value is a Lisp value as seen from Go.
+type Value struct {
+	o *object
+}
+
+// String returns the printed representation of the value.
+func (v Value) String() string {
+	return v.o.String()
+}
+
+// IsNil reports whether v holds no value, such as the result of a define.
+func (v Value) IsNil() bool {
+	return v.o == nil
+}
+
+// IsNull reports whether v is the empty list. Unlike a nil Value, the empty
+// list is a value.
+func (v Value) IsNull() bool {
+	return isEmptyList(v.o)
+}
+
+// IsBool reports whether v is a boolean.
+func (v Value) IsBool() bool {
+	return v.o != nil && v.o.t == TYPE_BOOL
+}
+
+// IsInt reports whether v is an integer, of any size.
+func (v Value) IsInt() bool {
+	return v.o != nil && (v.o.t == TYPE_INT || v.o.t == TYPE_BIGINT)
+}
+
+// IsFloat reports whether v is a floating point number.
+func (v Value) IsFloat() bool {
+	return v.o != nil && v.o.t == TYPE_FLOAT
+}
+
+// IsNumber reports whether v is a number.
+func (v Value) IsNumber() bool {
+	return isNumber(v.o)
+}
+
+// IsExact reports whether v is an exact number: an integer or a rational.
+func (v Value) IsExact() bool {
+	return isExact(v.o)
+}
+
+// IsSymbol reports whether v is a symbol.
+func (v Value) IsSymbol() bool {
+	return v.o != nil && (v.o.t == TYPE_SYMBOL || v.o.t == TYPE_BUILTIN)
+}
+
+// IsString reports whether v is a string.
+func (v Value) IsString() bool {
+	return v.o != nil && v.o.t == TYPE_STRING
+}
+
+// IsList reports whether v is a list.
+func (v Value) IsList() bool {
+	return isList(v.o)
+}
+
+// IsProcedure reports whether v can be called.
+func (v Value) IsProcedure() bool {
+	return v.o != nil && (v.o.t == TYPE_FN || v.o.t == TYPE_LAMBDA)
+}
+
+// Bool returns the value of a boolean.
+func (v Value) Bool() (bool, error) {
+	if !v.IsBool() {
+		return false, fmt.Errorf("%s is not a bool", v.typeName())
+	}
+	return v.o.b, nil
+}
+
+// Int returns the value of an integer.
+func (v Value) Int() (int64, error) {
+	if !v.IsInt() {
+		return 0, fmt.Errorf("%s is not an int", v.typeName())
+	}
+	if v.o.t == TYPE_BIGINT {
+		return 0, fmt.Errorf("%s overflows int64", v.o)
+	}
+	return v.o.i, nil
+}
+
+// Float returns the value of a number as a float64.
+func (v Value) Float() (float64, error) {
+	return v.o.toFloat()
+}
+
+// Symbol returns the name of a symbol.
+func (v Value) Symbol() (string, error) {
+	if !v.IsSymbol() {
+		return "", fmt.Errorf("%s is not a symbol", v.typeName())
+	}
+	return v.o.s, nil
+}
+
+// Str returns the contents of a string.
+func (v Value) Str() (string, error) {
+	if !v.IsString() {
+		return "", fmt.Errorf("%s is not a string", v.typeName())
+	}
+	return v.o.s, nil
+}
+
+// List returns the elements of a list.
+func (v Value) List() ([]Value, error) {
+	if !v.IsList() {
+		return nil, fmt.Errorf("%s is not a list", v.typeName())
+	}
+	os, err := toSlice(v.o)
+	if err != nil {
+		return nil, err
+	}
+	l := make([]Value, len(os))
+	for i, o := range os {
+		l[i] = Value{o}
+	}
+	return l, nil
+}
+
+func (v Value) typeName() string {
+	if v.o == nil {
+		return "nil"
+	}
+	return string(v.o.t)
+}
+
+// FromGo converts a Go value to a Lisp value. Booleans convert to booleans,
+// numbers, including *big.Int and *big.Rat, to numbers, strings to strings,
+// slices and arrays to lists, and maps and structs to association lists of
+// (key value) pairs. Pointers and interfaces are followed and nil converts to
+// no value.
+func FromGo(v interface{}) (Value, error) {
+	o, err := fromGo(reflect.ValueOf(v))
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{o}, nil
+}
+
+// ToGo stores the Lisp value v in the Go value pointed to by ptr, reversing
+// the conversions made by FromGo. If ptr points to an empty interface the
+// value is stored as a bool, int64, *big.Int for integers that overflow an
+// int64, *big.Rat for rationals, float64, string, []interface{} or Value.
+// Strings and symbols both convert to Go strings. Integers also convert to
+// *big.Int, and integers and rationals to *big.Rat.
+func ToGo(v Value, ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("expected non-nil pointer")
+	}
+	gv, err := toGo(v.o, rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(gv)
+	return nil
+}
+
+var (
+	valueType  = reflect.TypeOf(Value{})
+	objectType = reflect.TypeOf(&object{})
+	bigIntType = reflect.TypeOf(&big.Int{})
+	bigRatType = reflect.TypeOf(&big.Rat{})
+)
+
+func fromGo(v reflect.Value) (*object, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Type() {
+	case valueType:
+		return v.Interface().(Value).o, nil
+	case objectType:
+		return v.Interface().(*object), nil
+	case bigIntType:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return newInt(new(big.Int).Set(v.Interface().(*big.Int))), nil
+	case bigRatType:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return newRat(new(big.Rat).Set(v.Interface().(*big.Rat))), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return newObject(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return newObject(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return newInt(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return newObject(v.Float()), nil
+	case reflect.String:
+		return newString(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		l := make([]*object, v.Len())
+		for i := range l {
+			var err error
+			if l[i], err = fromGo(v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return list(l...), nil
+	case reflect.Map:
+		keys := v.MapKeys()
+		// Sort so that the conversion is deterministic.
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		l := make([]*object, len(keys))
+		for i, k := range keys {
+			ko, err := fromGo(k)
+			if err != nil {
+				return nil, err
+			}
+			vo, err := fromGo(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			l[i] = list(ko, vo)
+		}
+		return list(l...), nil
+	case reflect.Struct:
+		t := v.Type()
+		l := []*object{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fo, err := fromGo(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, list(newObject(f.Name), fo))
+		}
+		return list(l...), nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return fromGo(v.Elem())
+	case reflect.Func:
+		if fn, ok := v.Interface().(func(...*object) (*object, error)); ok {
+			return newObject(fn), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %s to a lisp value", v.Type())
+}
+
+func toGo(o *object, t reflect.Type) (reflect.Value, error) {
+	switch t {
+	case valueType:
+		return reflect.ValueOf(Value{o}), nil
+	case objectType:
+		return reflect.ValueOf(o), nil
+	case bigIntType:
+		if o != nil && (o.t == TYPE_INT || o.t == TYPE_BIGINT) {
+			r, _ := o.toRat()
+			return reflect.ValueOf(new(big.Int).Set(r.Num())), nil
+		}
+	case bigRatType:
+		if isExact(o) {
+			r, _ := o.toRat()
+			return reflect.ValueOf(new(big.Rat).Set(r)), nil
+		}
+	}
+
+	v := reflect.New(t).Elem()
+	if o == nil {
+		// No value converts to the zero value of any type.
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			break
+		}
+		n, err := toNative(o)
+		if err != nil {
+			return v, err
+		}
+		if n != nil {
+			v.Set(reflect.ValueOf(n))
+		}
+		return v, nil
+	case reflect.Bool:
+		if o.t != TYPE_BOOL {
+			break
+		}
+		v.SetBool(o.b)
+		return v, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if o.t == TYPE_BIGINT {
+			return v, fmt.Errorf("%s overflows %s", o, t)
+		}
+		if o.t != TYPE_INT {
+			break
+		}
+		if v.OverflowInt(o.i) {
+			return v, fmt.Errorf("%d overflows %s", o.i, t)
+		}
+		v.SetInt(o.i)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if o.t == TYPE_BIGINT {
+			if !o.big.IsUint64() || v.OverflowUint(o.big.Uint64()) {
+				return v, fmt.Errorf("%s overflows %s", o, t)
+			}
+			v.SetUint(o.big.Uint64())
+			return v, nil
+		}
+		if o.t != TYPE_INT {
+			break
+		}
+		if o.i < 0 || v.OverflowUint(uint64(o.i)) {
+			return v, fmt.Errorf("%d overflows %s", o.i, t)
+		}
+		v.SetUint(uint64(o.i))
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := o.toFloat()
+		if err != nil {
+			break
+		}
+		v.SetFloat(f)
+		return v, nil
+	case reflect.String:
+		if o.t != TYPE_STRING && o.t != TYPE_SYMBOL && o.t != TYPE_BUILTIN {
+			break
+		}
+		v.SetString(o.s)
+		return v, nil
+	case reflect.Slice:
+		l, err := toSlice(o)
+		if err != nil {
+			break
+		}
+		v.Set(reflect.MakeSlice(t, len(l), len(l)))
+		for i, e := range l {
+			ev, err := toGo(e, t.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case reflect.Array:
+		l, err := toSlice(o)
+		if err != nil {
+			break
+		}
+		if len(l) != t.Len() {
+			return v, fmt.Errorf("cannot convert list of length %d to %s", len(l), t)
+		}
+		for i, e := range l {
+			ev, err := toGo(e, t.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case reflect.Map:
+		l, err := toSlice(o)
+		if err != nil {
+			break
+		}
+		v.Set(reflect.MakeMap(t))
+		for _, p := range l {
+			kvl, err := toSlice(p)
+			if err != nil || len(kvl) != 2 {
+				return v, fmt.Errorf("expected (key value) pair converting to %s", t)
+			}
+			kv, err := toGo(kvl[0], t.Key())
+			if err != nil {
+				return v, err
+			}
+			vv, err := toGo(kvl[1], t.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.SetMapIndex(kv, vv)
+		}
+		return v, nil
+	case reflect.Struct:
+		l, err := toSlice(o)
+		if err != nil {
+			break
+		}
+		for _, p := range l {
+			fl, err := toSlice(p)
+			if err != nil || len(fl) != 2 || fl[0] == nil || (fl[0].t != TYPE_SYMBOL && fl[0].t != TYPE_BUILTIN && fl[0].t != TYPE_STRING) {
+				return v, fmt.Errorf("expected (field value) pair converting to %s", t)
+			}
+			f, ok := t.FieldByName(fl[0].s)
+			if !ok || f.PkgPath != "" {
+				return v, fmt.Errorf("%s has no field %s", t, fl[0].s)
+			}
+			fv, err := toGo(fl[1], f.Type)
+			if err != nil {
+				return v, err
+			}
+			v.FieldByIndex(f.Index).Set(fv)
+		}
+		return v, nil
+	case reflect.Ptr:
+		ev, err := toGo(o, t.Elem())
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(ev)
+		return p, nil
+	}
+	return v, fmt.Errorf("cannot convert %s to %s", o.t, t)
+}
+
+// toNative converts o to the natural Go type for its Lisp type.
+func toNative(o *object) (interface{}, error) {
+	if o == nil {
+		return nil, nil
+	}
+	switch o.t {
+	case TYPE_BOOL:
+		return o.b, nil
+	case TYPE_INT:
+		return o.i, nil
+	case TYPE_BIGINT:
+		return new(big.Int).Set(o.big), nil
+	case TYPE_RATIONAL:
+		return new(big.Rat).Set(o.rat), nil
+	case TYPE_FLOAT:
+		return o.f, nil
+	case TYPE_STRING, TYPE_SYMBOL, TYPE_BUILTIN:
+		return o.s, nil
+	case TYPE_LIST, TYPE_PAIR:
+		os, err := toSlice(o)
+		if err != nil {
+			return nil, err
+		}
+		l := make([]interface{}, len(os))
+		for i, e := range os {
+			var err error
+			if l[i], err = toNative(e); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	default:
+		return Value{o}, nil
+	}
+}
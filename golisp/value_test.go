@@ -0,0 +1,171 @@
+package golisp
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestValueAccessors(t *testing.T) {
+	v := Value{newObject([]*object{newObject(42), newObject(4.2), newObject("foo"), newString("bar")})}
+	if !v.IsList() || v.IsInt() || v.IsNil() {
+		t.Fatalf("unexpected predicates for %s", v)
+	}
+	l, err := v.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 4 {
+		t.Fatalf("got %d elements, want 4", len(l))
+	}
+
+	if i, err := l[0].Int(); err != nil || i != 42 {
+		t.Errorf("got %d, %v, want 42", i, err)
+	}
+	if f, err := l[0].Float(); err != nil || f != 42.0 {
+		t.Errorf("got %f, %v, want 42.0", f, err)
+	}
+	if f, err := l[1].Float(); err != nil || f != 4.2 {
+		t.Errorf("got %f, %v, want 4.2", f, err)
+	}
+	if s, err := l[2].Symbol(); err != nil || s != "foo" {
+		t.Errorf("got %q, %v, want foo", s, err)
+	}
+	if s, err := l[3].Str(); err != nil || s != "bar" {
+		t.Errorf("got %q, %v, want bar", s, err)
+	}
+	if _, err := l[2].Str(); !reflect.DeepEqual(err, errors.New("symbol is not a string")) {
+		t.Errorf("got err %q", err)
+	}
+
+	if _, err := l[1].Int(); !reflect.DeepEqual(err, errors.New("float is not an int")) {
+		t.Errorf("got err %q", err)
+	}
+	if _, err := l[0].Symbol(); !reflect.DeepEqual(err, errors.New("int is not a symbol")) {
+		t.Errorf("got err %q", err)
+	}
+	if _, err := (Value{}).List(); !reflect.DeepEqual(err, errors.New("nil is not a list")) {
+		t.Errorf("got err %q", err)
+	}
+	if b, err := (Value{newObject(true)}).Bool(); err != nil || !b {
+		t.Errorf("got %t, %v, want true", b, err)
+	}
+	if _, err := l[0].Bool(); !reflect.DeepEqual(err, errors.New("int is not a bool")) {
+		t.Errorf("got err %q", err)
+	}
+}
+
+type point struct {
+	X, Y    int
+	Label   string
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	cases := []struct {
+		v       interface{}
+		want    string
+		wantErr error
+	}{
+		{v: nil, want: ""},
+		{v: true, want: "#t"},
+		{v: uint8(7), want: "7"},
+		{v: 1.5, want: "1.5"},
+		{v: "foo", want: `"foo"`},
+		{v: []int{1, 2, 3}, want: "(1 2 3)"},
+		{v: [2]string{"a", "b"}, want: `("a" "b")`},
+		{v: map[string]int{"b": 2, "a": 1}, want: `(("a" 1) ("b" 2))`},
+		{v: point{X: 1, Y: 2, Label: "p"}, want: `((X 1) (Y 2) (Label "p"))`},
+		{v: &point{X: 1}, want: `((X 1) (Y 0) (Label ""))`},
+		{v: uint64(1 << 63), want: "9223372036854775808"},
+		{v: big.NewRat(1, 3), want: "1/3"},
+		{v: make(chan int), wantErr: errors.New("cannot convert chan int to a lisp value")},
+	}
+
+	for _, tt := range cases {
+		got, err := FromGo(tt.v)
+		if !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%#v: got err %q, want err %q", tt.v, err, tt.wantErr)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%#v: got %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	var i int
+	var u8 uint8
+	var f float32
+	var s string
+	var b bool
+	var ints []int
+	var m map[string]float64
+	var p point
+	var pp *point
+	var any interface{}
+
+	cases := []struct {
+		program string
+		ptr     interface{}
+		want    interface{}
+		wantErr error
+	}{
+		{program: "42", ptr: &i, want: 42},
+		{program: "255", ptr: &u8, want: uint8(255)},
+		{program: "256", ptr: &u8, wantErr: errors.New("256 overflows uint8")},
+		{program: "-1", ptr: &u8, wantErr: errors.New("-1 overflows uint8")},
+		{program: "4.2", ptr: &i, wantErr: errors.New("cannot convert float to int")},
+		{program: "42", ptr: &f, want: float32(42)},
+		{program: "(quote foo)", ptr: &s, want: "foo"},
+		{program: `"foo bar"`, ptr: &s, want: "foo bar"},
+		{program: "(> 2 1)", ptr: &b, want: true},
+		{program: "#f", ptr: &b, want: false},
+		{program: `"false"`, ptr: &b, wantErr: errors.New("cannot convert string to bool")},
+		{program: "5", ptr: &b, wantErr: errors.New("cannot convert int to bool")},
+		{program: "(list 1 2 3)", ptr: &ints, want: []int{1, 2, 3}},
+		{program: "(quote ((a 1) (b 2.5)))", ptr: &m, want: map[string]float64{"a": 1, "b": 2.5}},
+		{program: "(quote (a 1))", ptr: &m, wantErr: errors.New("expected (key value) pair converting to map[string]float64")},
+		{program: `(list (list (quote X) 1) (list (quote Label) "p"))`, ptr: &p, want: point{X: 1, Label: "p"}},
+		{program: "(quote ((Z 1)))", ptr: &p, wantErr: errors.New("golisp.point has no field Z")},
+		{program: "(quote ((Y 3)))", ptr: &pp, want: &point{Y: 3}},
+		{program: "(list 1 2.5 (quote (a)))", ptr: &any, want: []interface{}{int64(1), 2.5, []interface{}{"a"}}},
+	}
+
+	for _, tt := range cases {
+		v, err := New().Eval(tt.program)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.program, err)
+		}
+		err = ToGo(v, tt.ptr)
+		if !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%s: got err %q, want err %q", tt.program, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got := reflect.ValueOf(tt.ptr).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.program, got, tt.want)
+		}
+	}
+
+	if err := ToGo(Value{newObject(1)}, i); !reflect.DeepEqual(err, errors.New("expected non-nil pointer")) {
+		t.Errorf("got err %q", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := map[string][]point{"line": {{X: 1, Y: 2}, {X: 3, Y: 4, Label: "end"}}}
+	v, err := FromGo(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string][]point
+	if err := ToGo(v, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
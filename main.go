@@ -10,11 +10,33 @@ import (
 	"github.com/dominichamon/golisp/golisp"
 )
 
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
 var (
 	verbose = flag.Bool("verbose", false, "enable to get verbose logging")
+	expr    = flag.String("e", "", "evaluate `expr` and print the result")
+	scheme  = flag.Bool("scheme", false, "treat only #f as false, as in Scheme")
 )
 
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: golisp [flags] [script [args...]]
+
+Runs script, or the program piped to stdin, or starts a repl if stdin is a
+terminal. The script and its args are returned by (command-line).
+
+Exits with status %d if the program fails and %d for invalid flags.
+
+`, exitError, exitUsage)
+	flag.PrintDefaults()
+}
+
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
@@ -23,15 +45,48 @@ func main() {
 		log.SetOutput(os.Stdout)
 	}
 
-	if len(flag.Args()) == 0 {
-		if err := golisp.Repl(); err != nil {
-			log.Fatalf("%s", err)
+	os.Exit(run())
+}
+
+func run() int {
+	evalExpr := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "e" {
+			evalExpr = true
 		}
+	})
+
+	args := flag.Args()
+	opts := []golisp.Option{golisp.WithArgs(args...)}
+	if *scheme {
+		opts = append(opts, golisp.WithSchemeTruthiness())
+	}
+	interp := golisp.New(opts...)
+
+	var err error
+	switch {
+	case evalExpr:
+		var res golisp.Value
+		if res, err = interp.Eval(*expr); err == nil && !res.IsNil() {
+			fmt.Printf("%s\n", res)
+		}
+	case len(args) != 0:
+		_, err = interp.EvalFile(args[0])
+	case !isTerminal(os.Stdin):
+		_, err = interp.EvalReader(os.Stdin)
+	default:
+		err = interp.Repl()
 	}
 
-	res, err := golisp.Exec(flag.Arg(0))
 	if err != nil {
-		log.Fatalf("%s", err)
+		fmt.Fprintf(os.Stderr, "%s\n", golisp.RenderError(err))
+		return exitError
 	}
-	fmt.Printf("%s\n", res)
+	return exitOK
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }